@@ -1,142 +1,294 @@
 package GTFS
 
 import (
-	"encoding/csv"
-	"github.com/Gerrist/gtfs-cli/util"
-	"log"
+	"archive/zip"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 )
 
+// The gtfs tag of each field of a row names its column in the GTFS file. Rows read from a file keep the text of numbers
+// and times that Export wouldn't write back the same way, like optional values left empty, in their raw field.
+
 type Agency struct {
-	Id       string
-	Name     string
-	URL      string
-	Timezone string
-	Phone    string
+	Id       string `gtfs:"agency_id"`
+	Name     string `gtfs:"agency_name"`
+	URL      string `gtfs:"agency_url"`
+	Timezone string `gtfs:"agency_timezone"`
+	Phone    string `gtfs:"agency_phone"`
+}
+
+func (p *rowParser) agency() Agency {
+	return Agency{
+		Id:       p.string("agency_id"),
+		Name:     p.string("agency_name"),
+		URL:      p.string("agency_url"),
+		Timezone: p.string("agency_timezone"),
+		Phone:    p.string("agency_phone"),
+	}
 }
 
-func AgencyIndex(value string) int {
-	indices := []string{"agency_id", "agency_name", "agency_url", "agency_timezone", "agency_phone"}
-	return util.IndexOf(value, indices)
+type Calendar struct {
+	ServiceId string `gtfs:"service_id"`
+	Monday    int    `gtfs:"monday"`
+	Tuesday   int    `gtfs:"tuesday"`
+	Wednesday int    `gtfs:"wednesday"`
+	Thursday  int    `gtfs:"thursday"`
+	Friday    int    `gtfs:"friday"`
+	Saturday  int    `gtfs:"saturday"`
+	Sunday    int    `gtfs:"sunday"`
+	StartDate string `gtfs:"start_date"`
+	EndDate   string `gtfs:"end_date"`
+
+	raw rawValues
+}
+
+func (p *rowParser) calendar() Calendar {
+	return Calendar{
+		ServiceId: p.string("service_id"),
+		Monday:    p.int("monday"),
+		Tuesday:   p.int("tuesday"),
+		Wednesday: p.int("wednesday"),
+		Thursday:  p.int("thursday"),
+		Friday:    p.int("friday"),
+		Saturday:  p.int("saturday"),
+		Sunday:    p.int("sunday"),
+		StartDate: p.string("start_date"),
+		EndDate:   p.string("end_date"),
+		raw:       p.raw(),
+	}
 }
 
 type CalendarDate struct {
-	ServiceId     string
-	Date          string
-	ExceptionType int
+	ServiceId     string `gtfs:"service_id"`
+	Date          string `gtfs:"date"`
+	ExceptionType int    `gtfs:"exception_type"`
+
+	raw rawValues
 }
 
-func CalendarDateIndex(value string) int {
-	indices := []string{"service_id", "date", "exception_type"}
-	return util.IndexOf(value, indices)
+func (p *rowParser) calendarDate() CalendarDate {
+	return CalendarDate{
+		ServiceId:     p.string("service_id"),
+		Date:          p.string("date"),
+		ExceptionType: p.int("exception_type"),
+		raw:           p.raw(),
+	}
 }
 
 type Route struct {
-	RouteId        string
-	AgencyId       string
-	ExternalCode   string
-	RouteShortName string
-	RouteLongName  string
-	RouteDesc      string
-	RouteType      string
-	RouteColor     string
-	RouteTextColor string
-	RouteURL       string
+	RouteId        string `gtfs:"route_id"`
+	AgencyId       string `gtfs:"agency_id"`
+	ExternalCode   string `gtfs:"external_code"`
+	RouteShortName string `gtfs:"route_short_name"`
+	RouteLongName  string `gtfs:"route_long_name"`
+	RouteDesc      string `gtfs:"route_desc"`
+	RouteType      string `gtfs:"route_type"`
+	RouteColor     string `gtfs:"route_color"`
+	RouteTextColor string `gtfs:"route_text_color"`
+	RouteURL       string `gtfs:"route_url"`
 }
 
-func RouteIndex(value string) int {
-	indices := []string{"route_id", "agency_id", "external_code", "route_short_name", "route_long_name", "route_desc", "route_type", "route_color", "route_text_color", "route_url"}
-	return util.IndexOf(value, indices)
+func (p *rowParser) route() Route {
+	return Route{
+		RouteId:        p.string("route_id"),
+		AgencyId:       p.string("agency_id"),
+		ExternalCode:   p.string("external_code"),
+		RouteShortName: p.string("route_short_name"),
+		RouteLongName:  p.string("route_long_name"),
+		RouteDesc:      p.string("route_desc"),
+		RouteType:      p.string("route_type"),
+		RouteColor:     p.string("route_color"),
+		RouteTextColor: p.string("route_text_color"),
+		RouteURL:       p.string("route_url"),
+	}
 }
 
 type Shape struct {
-	Id           string
-	PTSequence   int
-	Lat          float64
-	Lon          float64
-	DistTraveled int
+	Id           string  `gtfs:"shape_id"`
+	PTSequence   int     `gtfs:"shape_pt_sequence"`
+	Lat          float64 `gtfs:"shape_pt_lat"`
+	Lon          float64 `gtfs:"shape_pt_lon"`
+	DistTraveled float64 `gtfs:"shape_dist_traveled"`
+
+	raw rawValues
 }
 
-func ShapeIndex(value string) int {
-	indices := []string{"shape_id", "shape_pt_sequence", "shape_pt_lat", "shape_pt_lon", "shape_dist_traveled"}
-	return util.IndexOf(value, indices)
+func (p *rowParser) shape() Shape {
+	return Shape{
+		Id:           p.string("shape_id"),
+		PTSequence:   p.int("shape_pt_sequence"),
+		Lat:          p.float("shape_pt_lat"),
+		Lon:          p.float("shape_pt_lon"),
+		DistTraveled: p.float("shape_dist_traveled"),
+		raw:          p.raw(),
+	}
+}
+
+// HasCoordinates tells whether both shape_pt_lat and shape_pt_lon of the point are given
+func (shape Shape) HasCoordinates() bool {
+	return (shape.Lat != 0 || shape.raw.isSet("shape_pt_lat")) && (shape.Lon != 0 || shape.raw.isSet("shape_pt_lon"))
+}
+
+// HasDistTraveled tells whether the point gives a shape_dist_traveled, which feeds may leave empty
+func (shape Shape) HasDistTraveled() bool {
+	return shape.DistTraveled != 0 || shape.raw.isSet("shape_dist_traveled")
 }
 
 type StopTime struct {
-	TripId            string
-	Sequence          int
-	StopId            string
-	StopHeadsign      string
-	ArrivalTime       string
-	DepartureTime     string
-	PickUpType        int
-	DropOffType       int
-	Timepoint         int
-	ShapeDistTraveled int
-	FareUnitsTraveled int
+	TripId            string  `gtfs:"trip_id"`
+	Sequence          int     `gtfs:"stop_sequence"`
+	StopId            string  `gtfs:"stop_id"`
+	StopHeadsign      string  `gtfs:"stop_headsign"`
+	ArrivalTime       Time    `gtfs:"arrival_time"`
+	DepartureTime     Time    `gtfs:"departure_time"`
+	PickUpType        int     `gtfs:"pickup_type"`
+	DropOffType       int     `gtfs:"drop_off_type"`
+	Timepoint         int     `gtfs:"timepoint"`
+	ShapeDistTraveled float64 `gtfs:"shape_dist_traveled"`
+	FareUnitsTraveled int     `gtfs:"fare_units_traveled"`
+
+	raw rawValues
+}
+
+func (p *rowParser) stopTime() StopTime {
+	return StopTime{
+		TripId:            p.string("trip_id"),
+		Sequence:          p.int("stop_sequence"),
+		StopId:            p.string("stop_id"),
+		StopHeadsign:      p.string("stop_headsign"),
+		ArrivalTime:       p.time("arrival_time"),
+		DepartureTime:     p.time("departure_time"),
+		PickUpType:        p.int("pickup_type"),
+		DropOffType:       p.int("drop_off_type"),
+		Timepoint:         p.int("timepoint"),
+		ShapeDistTraveled: p.float("shape_dist_traveled"),
+		FareUnitsTraveled: p.int("fare_units_traveled"),
+		raw:               p.raw(),
+	}
 }
 
-func StopTimeIndex(value string) int {
-	indices := []string{"trip_id", "stop_sequence", "stop_id", "stop_headsign", "arrival_time", "departure_time", "pickup_type", "drop_off_type", "timepoint", "shape_dist_traveled", "fare_units_traveled"}
-	return util.IndexOf(value, indices)
+// HasShapeDistTraveled tells whether the stop time gives a shape_dist_traveled, which feeds may leave empty
+func (stopTime StopTime) HasShapeDistTraveled() bool {
+	return stopTime.ShapeDistTraveled != 0 || stopTime.raw.isSet("shape_dist_traveled")
 }
 
 type Stop struct {
-	Id                 string
-	Code               string
-	Name               string
-	Lat                float64
-	Lon                float64
-	LocationType       int
-	ParentStation      string
-	StopTimezone       string
-	WheelchairBoarding int
-	PlatformCode       string
-	ZoneId             string
+	Id                 string  `gtfs:"stop_id"`
+	Code               string  `gtfs:"stop_code"`
+	Name               string  `gtfs:"stop_name"`
+	Lat                float64 `gtfs:"stop_lat"`
+	Lon                float64 `gtfs:"stop_lon"`
+	LocationType       int     `gtfs:"location_type"`
+	ParentStation      string  `gtfs:"parent_station"`
+	StopTimezone       string  `gtfs:"stop_timezone"`
+	WheelchairBoarding int     `gtfs:"wheelchair_boarding"`
+	PlatformCode       string  `gtfs:"platform_code"`
+	ZoneId             string  `gtfs:"zone_id"`
+
+	raw rawValues
 }
 
-func StopIndex(value string) int {
-	indices := []string{"stop_id", "stop_code", "stop_name", "stop_lat", "stop_lon", "location_type", "parent_station", "stop_timezone", "wheelchair_boarding", "platform_code", "zone_id"}
-	return util.IndexOf(value, indices)
+func (p *rowParser) stop() Stop {
+	return Stop{
+		Id:                 p.string("stop_id"),
+		Code:               p.string("stop_code"),
+		Name:               p.string("stop_name"),
+		Lat:                p.float("stop_lat"),
+		Lon:                p.float("stop_lon"),
+		LocationType:       p.int("location_type"),
+		ParentStation:      p.string("parent_station"),
+		StopTimezone:       p.string("stop_timezone"),
+		WheelchairBoarding: p.int("wheelchair_boarding"),
+		PlatformCode:       p.string("platform_code"),
+		ZoneId:             p.string("zone_id"),
+		raw:                p.raw(),
+	}
+}
+
+// HasCoordinates tells whether both stop_lat and stop_lon of the stop are given, as they may be left empty for generic
+// nodes and boarding areas
+func (stop Stop) HasCoordinates() bool {
+	return (stop.Lat != 0 || stop.raw.isSet("stop_lat")) && (stop.Lon != 0 || stop.raw.isSet("stop_lon"))
 }
 
 type Transfer struct {
-	FromStopId   string
-	ToStopId     string
-	FromRouteId  string
-	ToRouteId    string
-	FromTripId   string
-	ToTripId     string
-	TransferType int
+	FromStopId      string `gtfs:"from_stop_id"`
+	ToStopId        string `gtfs:"to_stop_id"`
+	FromRouteId     string `gtfs:"from_route_id"`
+	ToRouteId       string `gtfs:"to_route_id"`
+	FromTripId      string `gtfs:"from_trip_id"`
+	ToTripId        string `gtfs:"to_trip_id"`
+	TransferType    int    `gtfs:"transfer_type"`
+	MinTransferTime int    `gtfs:"min_transfer_time"` // seconds needed to transfer, 0 when not given
+
+	raw rawValues
 }
 
-func TransferIndex(value string) int {
-	indices := []string{"from_stop_id", "to_stop_id", "from_route_id", "to_route_id", "from_trip_id", "to_trip_id", "transfer_type"}
-	return util.IndexOf(value, indices)
+func (p *rowParser) transfer() Transfer {
+	return Transfer{
+		FromStopId:      p.string("from_stop_id"),
+		ToStopId:        p.string("to_stop_id"),
+		FromRouteId:     p.string("from_route_id"),
+		ToRouteId:       p.string("to_route_id"),
+		FromTripId:      p.string("from_trip_id"),
+		ToTripId:        p.string("to_trip_id"),
+		TransferType:    p.int("transfer_type"),
+		MinTransferTime: p.int("min_transfer_time"),
+		raw:             p.raw(),
+	}
 }
 
 type Trip struct {
-	RouteId              string
-	ServiceId            string
-	TripId               string
-	RealtimeTripId       string
-	TripHeadsign         string
-	TripShortName        string
-	TripLongName         string
-	DirectionId          int
-	BlockId              int
-	ShapeId              string
-	WheelchairAccessible int
-	BikesAllowed         int
+	RouteId              string `gtfs:"route_id"`
+	ServiceId            string `gtfs:"service_id"`
+	TripId               string `gtfs:"trip_id"`
+	RealtimeTripId       string `gtfs:"realtime_trip_id"`
+	TripHeadsign         string `gtfs:"trip_headsign"`
+	TripShortName        string `gtfs:"trip_short_name"`
+	TripLongName         string `gtfs:"trip_long_name"`
+	DirectionId          int    `gtfs:"direction_id"`
+	BlockId              string `gtfs:"block_id"`
+	ShapeId              string `gtfs:"shape_id"`
+	WheelchairAccessible int    `gtfs:"wheelchair_accessible"`
+	BikesAllowed         int    `gtfs:"bikes_allowed"`
+
+	raw rawValues
+}
+
+func (p *rowParser) trip() Trip {
+	return Trip{
+		RouteId:              p.string("route_id"),
+		ServiceId:            p.string("service_id"),
+		TripId:               p.string("trip_id"),
+		RealtimeTripId:       p.string("realtime_trip_id"),
+		TripHeadsign:         p.string("trip_headsign"),
+		TripShortName:        p.string("trip_short_name"),
+		TripLongName:         p.string("trip_long_name"),
+		DirectionId:          p.int("direction_id"),
+		BlockId:              p.string("block_id"),
+		ShapeId:              p.string("shape_id"),
+		WheelchairAccessible: p.int("wheelchair_accessible"),
+		BikesAllowed:         p.int("bikes_allowed"),
+		raw:                  p.raw(),
+	}
 }
 
-func TripIndex(value string) int {
-	indices := []string{"route_id", "service_id", "trip_id", "realtime_trip_id", "trip_headsign", "trip_short_name", "trip_long_name", "direction_id", "block_id", "shape_id", "wheelchair_accessible", "bikes_allowed"}
-	return util.IndexOf(value, indices)
+// FileTypes lists the files of a feed that the Store can hold, in the order Load reads them
+var FileTypes = []string{"agency", "calendar", "calendar_dates", "routes", "shapes", "stop_times", "stops", "transfers", "trips"}
+
+// files a feed is allowed to leave out
+var optionalFiles = map[string]bool{
+	"calendar":       true,
+	"calendar_dates": true,
+	"shapes":         true,
+	"transfers":      true,
 }
 
 type Store struct {
 	Agency        []Agency
+	Calendar      []Calendar
 	CalendarDates []CalendarDate
 	Route         []Route
 	Shape         []Shape
@@ -144,306 +296,100 @@ type Store struct {
 	Stop          []Stop
 	Transfer      []Transfer
 	Trip          []Trip
-}
 
-func (store *Store) ReadFile(fileType, filePath string) { // we need both fileType and filePath, so we can support having multiple files of one type
-	gtfsFile, err := os.Open(filePath)
-	gtfsFileLines, err := csv.NewReader(gtfsFile).ReadAll()
-
-	switch fileType {
-	case "agency":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				agency := Agency{
-					Id:       line[AgencyIndex("agency_id")],
-					Name:     line[AgencyIndex("agency_name")],
-					URL:      line[AgencyIndex("agency_url")],
-					Timezone: line[AgencyIndex("agency_timezone")],
-					Phone:    line[AgencyIndex("agency_phone")],
-				}
-				store.Agency = append(store.Agency, agency)
-			}
-		}
-		break
-	case "calendar_dates":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				calendarDate := CalendarDate{
-					ServiceId:     line[CalendarDateIndex("service_id")],
-					Date:          line[CalendarDateIndex("date")],
-					ExceptionType: util.ParseInt(line[CalendarDateIndex("exception_type")]),
-				}
-
-				store.CalendarDates = append(store.CalendarDates, calendarDate)
-			}
-		}
-		break
-	case "routes":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				route := Route{
-					RouteId:        line[RouteIndex("route_id")],
-					AgencyId:       line[RouteIndex("agency_id")],
-					ExternalCode:   line[RouteIndex("external_code")],
-					RouteShortName: line[RouteIndex("route_short_name")],
-					RouteLongName:  line[RouteIndex("route_long_name")],
-					RouteDesc:      line[RouteIndex("route_desc")],
-					RouteType:      line[RouteIndex("route_type")],
-					RouteColor:     line[RouteIndex("route_color")],
-					RouteTextColor: line[RouteIndex("route_text_color")],
-					RouteURL:       line[RouteIndex("route_url")],
-				}
-
-				store.Route = append(store.Route, route)
-			}
-		}
-		break
-	case "shapes":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				shape := Shape{
-					Id:           line[ShapeIndex("shape_id")],
-					PTSequence:   util.ParseInt(line[ShapeIndex("shape_pt_sequence")]),
-					Lat:          util.ParseFloat(line[ShapeIndex("shape_pt_lat")]),
-					Lon:          util.ParseFloat(line[ShapeIndex("shape_pt_lon")]),
-					DistTraveled: util.ParseInt(line[ShapeIndex("shape_dist_traveled")]),
-				}
-
-				store.Shape = append(store.Shape, shape)
-			}
-		}
-		break
-	case "stop_times":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				stopTime := StopTime{
-					TripId:            line[StopTimeIndex("trip_id")],
-					Sequence:          util.ParseInt(line[StopTimeIndex("stop_sequence")]),
-					StopId:            line[StopTimeIndex("stop_id")],
-					StopHeadsign:      line[StopTimeIndex("stop_headsign")],
-					ArrivalTime:       line[StopTimeIndex("arrival_time")],
-					DepartureTime:     line[StopTimeIndex("departure_time")],
-					PickUpType:        util.ParseInt(line[StopTimeIndex("pickup_type")]),
-					DropOffType:       util.ParseInt(line[StopTimeIndex("drop_off_type")]),
-					Timepoint:         util.ParseInt(line[StopTimeIndex("timepoint")]),
-					ShapeDistTraveled: util.ParseInt(line[StopTimeIndex("shape_dist_traveled")]),
-					FareUnitsTraveled: util.ParseInt(line[StopTimeIndex("fare_units_traveled")]),
-				}
-
-				store.StopTime = append(store.StopTime, stopTime)
-			}
-		}
-		break
-	case "stops":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				stop := Stop{
-					Id:                 line[StopIndex("stop_id")],
-					Code:               line[StopIndex("stop_code")],
-					Name:               line[StopIndex("stop_name")],
-					Lat:                util.ParseFloat(line[StopIndex("stop_lat")]),
-					Lon:                util.ParseFloat(line[StopIndex("stop_lon")]),
-					LocationType:       util.ParseInt(line[StopIndex("location_type")]),
-					ParentStation:      line[StopIndex("parent_station")],
-					StopTimezone:       line[StopIndex("stop_timezone")],
-					WheelchairBoarding: util.ParseInt(line[StopIndex("wheelchair_boarding")]),
-					PlatformCode:       line[StopIndex("platform_code")],
-					ZoneId:             line[StopIndex("zone_id")],
-				}
-
-				store.Stop = append(store.Stop, stop)
-			}
-		}
-		break
-	case "transfers":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				transfer := Transfer{
-					FromStopId:   line[TransferIndex("from_stop_id")],
-					ToStopId:     line[TransferIndex("to_stop_id")],
-					FromRouteId:  line[TransferIndex("from_route_id")],
-					ToRouteId:    line[TransferIndex("to_route_id")],
-					FromTripId:   line[TransferIndex("from_trip_id")],
-					ToTripId:     line[TransferIndex("to_trip_id")],
-					TransferType: util.ParseInt(line[TransferIndex("transfer_type")]),
-				}
-
-				store.Transfer = append(store.Transfer, transfer)
-			}
-		}
-		break
-	case "trips":
-		for i, line := range gtfsFileLines {
-			if i > 0 { // Skip header line
-				trip := Trip{
-					RouteId:              line[TripIndex("route_id")],
-					ServiceId:            line[TripIndex("service_id")],
-					TripId:               line[TripIndex("trip_id")],
-					RealtimeTripId:       line[TripIndex("realtime_trip_id")],
-					TripHeadsign:         line[TripIndex("trip_headsign")],
-					TripShortName:        line[TripIndex("trip_short_name")],
-					TripLongName:         line[TripIndex("trip_long_name")],
-					DirectionId:          util.ParseInt(line[TripIndex("direction_id")]),
-					BlockId:              util.ParseInt(line[TripIndex("block_id")]),
-					ShapeId:              line[TripIndex("shape_id")],
-					WheelchairAccessible: util.ParseInt(line[TripIndex("wheelchair_accessible")]),
-					BikesAllowed:         util.ParseInt(line[TripIndex("bikes_allowed")]),
-				}
-
-				store.Trip = append(store.Trip, trip)
-			}
-		}
-		break
+	Lenient  bool          // when set, malformed values are collected in Warnings and read as zero instead of failing the file
+	Warnings []*ParseError // malformed values encountered while reading leniently
+}
 
+// Load reads every file of the feed stored at path, which is either a directory or a zip archive
+func (store *Store) Load(path string) error {
+	reader, err := OpenReader(path)
+	if err != nil {
+		return err
 	}
+	defer reader.Close()
 
-	//calendarDatesFile, err := os.Open("./gtfs-data/calendar_dates.txt")
-	//calendarDatesLines, err := csv.NewReader(calendarDatesFile).ReadAll()
+	return store.LoadFrom(reader)
+}
 
+// LoadZip reads every file of the feed stored in the zip archive r of size bytes
+func (store *Store) LoadZip(r io.ReaderAt, size int64) error {
+	archive, err := zip.NewReader(r, size)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
+	return store.LoadFS(archive)
 }
 
-func (store *Store) Export(exportName string) {
-	_ = os.Mkdir(exportName, 0755)
-
-	os.Remove(exportName + "/stops.txt")
-	stopsFile, _ := os.OpenFile(exportName+"/stops.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	stopsFile.WriteString(util.CSVRow([]interface{}{"stop_id", "stop_code", "stop_name", "stop_lat", "stop_lon", "location_type", "parent_station", "stop_timezone", "wheelchair_boarding", "platform_code", "zone_id"}))
-	for _, stop := range store.Stop {
-		var row = []interface{}{}
-		row = append(row, stop.Id)
-		row = append(row, stop.Code)
-		row = append(row, stop.Name)
-		row = append(row, stop.Lat)
-		row = append(row, stop.Lon)
-		row = append(row, stop.LocationType)
-		row = append(row, stop.ParentStation)
-		row = append(row, stop.StopTimezone)
-		row = append(row, stop.WheelchairBoarding)
-		row = append(row, stop.PlatformCode)
-		row = append(row, stop.ZoneId)
-
-		stopsFile.WriteString(util.CSVRow(row))
+// LoadFS reads every file of the feed stored in fsys
+func (store *Store) LoadFS(fsys fs.FS) error {
+	reader, err := NewReader(fsys)
+	if err != nil {
+		return err
 	}
 
-	os.Remove(exportName + "/agency.txt")
-	agencyFile, _ := os.OpenFile(exportName+"/agency.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	agencyFile.WriteString(util.CSVRow([]interface{}{"agency_id", "agency_name", "agency_url", "agency_timezone", "agency_phone"}))
-	for _, agency := range store.Agency {
-		var row = []interface{}{}
-		row = append(row, agency.Id)
-		row = append(row, agency.Name)
-		row = append(row, agency.URL)
-		row = append(row, agency.Timezone)
-		row = append(row, agency.Phone)
-
-		agencyFile.WriteString(util.CSVRow(row))
+	return store.LoadFrom(reader)
+}
+
+// LoadFrom reads the given file types from reader, or every file type when none are given
+func (store *Store) LoadFrom(reader *Reader, fileTypes ...string) error {
+	if len(fileTypes) == 0 {
+		fileTypes = FileTypes
 	}
 
-	os.Remove(exportName + "/calendar_dates.txt")
-	calendarFile, _ := os.OpenFile(exportName+"/calendar_dates.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	calendarFile.WriteString(util.CSVRow([]interface{}{"service_id", "date", "exception_type"}))
-	for _, calendarDate := range store.CalendarDates {
-		var row = []interface{}{}
-		row = append(row, calendarDate.ServiceId)
-		row = append(row, calendarDate.Date)
-		row = append(row, calendarDate.ExceptionType)
+	reader.Lenient = store.Lenient
+	defer func() {
+		store.Warnings = append(store.Warnings, reader.Warnings...)
+		reader.Warnings = nil
+	}()
 
-		calendarFile.WriteString(util.CSVRow(row))
+	for _, fileType := range fileTypes {
+		if err := store.read(reader, fileType, fileType+".txt"); err != nil {
+			return err
+		}
 	}
 
-	os.Remove(exportName + "/routes.txt")
-	routesFile, _ := os.OpenFile(exportName+"/routes.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	routesFile.WriteString(util.CSVRow([]interface{}{"route_id", "agency_id", "external_code", "route_short_name", "route_long_name", "route_desc", "route_type", "route_color", "route_text_color", "route_url"}))
-	for _, route := range store.Route {
-		var row = []interface{}{}
-		row = append(row, route.RouteId)
-		row = append(row, route.AgencyId)
-		row = append(row, route.ExternalCode)
-		row = append(row, route.RouteShortName)
-		row = append(row, route.RouteLongName)
-		row = append(row, route.RouteDesc)
-		row = append(row, route.RouteType)
-		row = append(row, route.RouteColor)
-		row = append(row, route.RouteTextColor)
-		row = append(row, route.RouteURL)
-
-		routesFile.WriteString(util.CSVRow(row))
-	}
+	return nil
+}
 
-	os.Remove(exportName + "/shapes.txt")
-	shapesFile, _ := os.OpenFile(exportName+"/shapes.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	shapesFile.WriteString(util.CSVRow([]interface{}{"shape_id", "shape_pt_sequence", "shape_pt_lat", "shape_pt_lon", "shape_dist_traveled"}))
-	for _, shape := range store.Shape {
-		var row = []interface{}{}
-		row = append(row, shape.Id)
-		row = append(row, shape.PTSequence)
-		row = append(row, shape.Lat)
-		row = append(row, shape.Lon)
-		row = append(row, shape.DistTraveled)
-
-		shapesFile.WriteString(util.CSVRow(row))
+func (store *Store) ReadFile(fileType, filePath string) error { // we need both fileType and filePath, so we can support having multiple files of one type
+	if _, err := os.Stat(filePath); err != nil {
+		return err
 	}
 
-	os.Remove(exportName + "/stop_times.txt")
-	stopTimesFile, _ := os.OpenFile(exportName+"/stop_times.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	stopTimesFile.WriteString(util.CSVRow([]interface{}{"trip_id", "stop_sequence", "stop_id", "stop_headsign", "arrival_time", "departure_time", "pickup_type", "drop_off_type", "timepoint", "shape_dist_traveled", "fare_units_traveled"}))
-	for _, stopTime := range store.StopTime {
-		var row = []interface{}{}
-		row = append(row, stopTime.TripId)
-		row = append(row, stopTime.Sequence)
-		row = append(row, stopTime.StopId)
-		row = append(row, stopTime.StopHeadsign)
-		row = append(row, stopTime.ArrivalTime)
-		row = append(row, stopTime.DepartureTime)
-		row = append(row, stopTime.PickUpType)
-		row = append(row, stopTime.DropOffType)
-		row = append(row, stopTime.Timepoint)
-		row = append(row, stopTime.ShapeDistTraveled)
-		row = append(row, stopTime.FareUnitsTraveled)
-
-		stopTimesFile.WriteString(util.CSVRow(row))
-	}
+	dir := filepath.Dir(filePath)
+	reader := &Reader{Lenient: store.Lenient, fsys: os.DirFS(dir), location: dir}
+	defer func() {
+		store.Warnings = append(store.Warnings, reader.Warnings...)
+	}()
 
-	os.Remove(exportName + "/transfers.txt")
-	transfersFile, _ := os.OpenFile(exportName+"/transfers.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	transfersFile.WriteString(util.CSVRow([]interface{}{"from_stop_id", "to_stop_id", "from_route_id", "to_route_id", "from_trip_id", "to_trip_id", "transfer_type"}))
-	for _, stop := range store.Transfer {
-		var row = []interface{}{}
-		row = append(row, stop.FromStopId)
-		row = append(row, stop.ToStopId)
-		row = append(row, stop.FromRouteId)
-		row = append(row, stop.ToRouteId)
-		row = append(row, stop.FromStopId)
-		row = append(row, stop.ToStopId)
-		row = append(row, stop.FromTripId)
-		row = append(row, stop.ToTripId)
-		row = append(row, stop.TransferType)
-
-		transfersFile.WriteString(util.CSVRow(row))
-	}
+	return store.read(reader, fileType, filepath.Base(filePath))
+}
 
-	os.Remove(exportName + "/trips.txt")
-	tripsFile, _ := os.OpenFile(exportName+"/trips.txt", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
-	tripsFile.WriteString(util.CSVRow([]interface{}{"route_id", "service_id", "trip_id", "realtime_trip_id", "trip_headsign", "trip_short_name", "trip_long_name", "direction_id", "block_id", "shape_id", "wheelchair_accessible", "bikes_allowed"}))
-	for _, trip := range store.Trip {
-		var row = []interface{}{}
-		row = append(row, trip.RouteId)
-		row = append(row, trip.ServiceId)
-		row = append(row, trip.TripId)
-		row = append(row, trip.RealtimeTripId)
-		row = append(row, trip.TripHeadsign)
-		row = append(row, trip.TripShortName)
-		row = append(row, trip.TripLongName)
-		row = append(row, trip.DirectionId)
-		row = append(row, trip.BlockId)
-		row = append(row, trip.ShapeId)
-		row = append(row, trip.WheelchairAccessible)
-		row = append(row, trip.BikesAllowed)
-
-		tripsFile.WriteString(util.CSVRow(row))
-	}
+// read adds the records of fileName, a file of type fileType, to the store
+func (store *Store) read(reader *Reader, fileType, fileName string) error {
+	return reader.eachRecord(fileType, fileName, func(p *rowParser) error {
+		switch fileType {
+		case "agency":
+			store.Agency = append(store.Agency, p.agency())
+		case "calendar":
+			store.Calendar = append(store.Calendar, p.calendar())
+		case "calendar_dates":
+			store.CalendarDates = append(store.CalendarDates, p.calendarDate())
+		case "routes":
+			store.Route = append(store.Route, p.route())
+		case "shapes":
+			store.Shape = append(store.Shape, p.shape())
+		case "stop_times":
+			store.StopTime = append(store.StopTime, p.stopTime())
+		case "stops":
+			store.Stop = append(store.Stop, p.stop())
+		case "transfers":
+			store.Transfer = append(store.Transfer, p.transfer())
+		case "trips":
+			store.Trip = append(store.Trip, p.trip())
+		}
+		return nil
+	})
 }
@@ -0,0 +1,155 @@
+package GTFS
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrUnknownStop  = errors.New("unknown stop")
+	ErrInvalidCount = errors.New("count should be at least 1")
+)
+
+// values of StopTime.PickUpType
+const (
+	PickupRegular     = 0
+	PickupNone        = 1
+	PickupPhoneAgency = 2
+	PickupAskDriver   = 3
+)
+
+// days after the queried date searched for departures, so stops with little service still list something
+const departureLookahead = 7
+
+type Departure struct {
+	Time           time.Time `json:"time"`
+	StopId         string    `json:"stop_id"`
+	PlatformCode   string    `json:"platform_code,omitempty"`
+	TripId         string    `json:"trip_id"`
+	RouteId        string    `json:"route_id"`
+	RouteShortName string    `json:"route_short_name"`
+	Headsign       string    `json:"headsign"`
+	PickupType     int       `json:"pickup_type"`
+}
+
+// Departures lists the next count departures from a stop at or after at. When station is set, or the stop is a
+// station, departures from all platforms of its parent station are listed as well. Departure times are in the
+// timezone of the agency running the trip.
+func (feed *Feed) Departures(stopId string, at time.Time, count int, station bool) ([]Departure, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidCount, count)
+	}
+	stop := feed.Stop(stopId)
+	if stop == nil {
+		return nil, fmt.Errorf("%w %s", ErrUnknownStop, stopId)
+	}
+	calendar, err := feed.ServiceCalendar()
+	if err != nil {
+		return nil, err
+	}
+
+	stops := []*Stop{stop}
+	if station && stop.ParentStation != "" && stop.LocationType == 0 {
+		if parent := feed.Stop(stop.ParentStation); parent != nil {
+			stops = []*Stop{parent}
+		}
+	}
+	if station || stop.LocationType == 1 {
+		stops = feed.withChildren(stops)
+	}
+
+	locations := map[string]*time.Location{}
+	departures := make([]Departure, 0)
+	for offset := -1; offset <= departureLookahead; offset++ {
+		// trips of the previous service day can still be running, as times go past 24:00:00
+		date := at.AddDate(0, 0, offset)
+		for _, stop := range stops {
+			for _, stopTime := range feed.StopStopTimes(stop.Id) {
+				departure, ok, err := feed.departure(stopTime, date, calendar, locations)
+				if err != nil {
+					return nil, err
+				}
+				if ok && !departure.Time.Before(at) {
+					departure.PlatformCode = stop.PlatformCode
+					departures = append(departures, departure)
+				}
+			}
+		}
+
+		sort.SliceStable(departures, func(i, j int) bool {
+			return departures[i].Time.Before(departures[j].Time)
+		})
+		// later service days only start around midnight, so once enough departures are found before then, they are final
+		nextDay := NewTime(0, 0, 0).On(at.AddDate(0, 0, offset+1), at.Location())
+		if offset >= 0 && len(departures) >= count && departures[count-1].Time.Before(nextDay) {
+			break
+		}
+	}
+
+	if len(departures) > count {
+		departures = departures[:count]
+	}
+	return departures, nil
+}
+
+// withChildren adds the stops below the given stops, such as the platforms of a station
+func (feed *Feed) withChildren(stops []*Stop) []*Stop {
+	all := make([]*Stop, 0, len(stops))
+	seen := map[string]bool{}
+	for len(stops) > 0 {
+		stop := stops[0]
+		stops = stops[1:]
+		if seen[stop.Id] {
+			continue
+		}
+		seen[stop.Id] = true
+		all = append(all, stop)
+		stops = append(stops, feed.Children(stop.Id)...)
+	}
+	return all
+}
+
+// departure describes the departure of a stop time on the service day date, if its trip runs that day and passengers
+// can leave from it
+func (feed *Feed) departure(stopTime *StopTime, date time.Time, calendar *ServiceCalendar, locations map[string]*time.Location) (Departure, bool, error) {
+	trip := feed.Trip(stopTime.TripId)
+	if trip == nil || !calendar.IsActive(trip.ServiceId, date) {
+		return Departure{}, false, nil
+	}
+
+	// the last stop of a trip is only arrived at
+	stopTimes := feed.TripStopTimes(trip.TripId)
+	if stopTimes[len(stopTimes)-1].Sequence == stopTime.Sequence {
+		return Departure{}, false, nil
+	}
+	departureTime := stopTime.DepartureTime
+	if !departureTime.IsSet() {
+		departureTime = stopTime.ArrivalTime
+	}
+	if !departureTime.IsSet() {
+		return Departure{}, false, nil
+	}
+
+	departure := Departure{
+		StopId:     stopTime.StopId,
+		TripId:     trip.TripId,
+		RouteId:    trip.RouteId,
+		Headsign:   stopTime.StopHeadsign,
+		PickupType: stopTime.PickUpType,
+	}
+	if departure.Headsign == "" {
+		departure.Headsign = trip.TripHeadsign
+	}
+
+	if route := feed.Route(trip.RouteId); route != nil {
+		departure.RouteShortName = route.RouteShortName
+	}
+	location, err := feed.tripLocation(trip, date.Location(), locations)
+	if err != nil {
+		return Departure{}, false, err
+	}
+	departure.Time = departureTime.On(date, location)
+	return departure, true, nil
+}
@@ -0,0 +1,74 @@
+package GTFS
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDepartures(t *testing.T) {
+	store := Store{
+		Agency:   []Agency{{Id: "A", Timezone: "Europe/Amsterdam"}},
+		Calendar: []Calendar{{ServiceId: "daily", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, Saturday: 1, Sunday: 1, StartDate: "20261001", EndDate: "20261031"}},
+		Route:    []Route{{RouteId: "r", AgencyId: "A", RouteShortName: "5"}},
+		Stop: []Stop{
+			{Id: "station", LocationType: 1},
+			{Id: "p1", ParentStation: "station", PlatformCode: "1"},
+			{Id: "p2", ParentStation: "station", PlatformCode: "2"},
+			{Id: "end"},
+		},
+		Trip: []Trip{
+			{RouteId: "r", ServiceId: "daily", TripId: "late", TripHeadsign: "End"},
+			{RouteId: "r", ServiceId: "daily", TripId: "morning", TripHeadsign: "End"},
+		},
+		StopTime: []StopTime{
+			{TripId: "late", Sequence: 1, StopId: "p2", DepartureTime: NewTime(24, 30, 0), StopHeadsign: "Night"},
+			{TripId: "late", Sequence: 2, StopId: "end", ArrivalTime: NewTime(24, 50, 0)},
+			{TripId: "morning", Sequence: 1, StopId: "p1", DepartureTime: NewTime(7, 0, 0), PickUpType: PickupAskDriver},
+			{TripId: "morning", Sequence: 2, StopId: "end", ArrivalTime: NewTime(7, 20, 0)},
+		},
+	}
+	feed := NewFeed(&store)
+	amsterdam, _ := time.LoadLocation("Europe/Amsterdam")
+	at := time.Date(2026, 10, 18, 0, 10, 0, 0, amsterdam)
+
+	departures, err := feed.Departures("p1", at, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		trip, headsign, platform string
+		time                     time.Time
+	}{
+		{"late", "Night", "2", time.Date(2026, 10, 18, 0, 30, 0, 0, amsterdam)}, // of the service day before
+		{"morning", "End", "1", time.Date(2026, 10, 18, 7, 0, 0, 0, amsterdam)},
+		{"late", "Night", "2", time.Date(2026, 10, 19, 0, 30, 0, 0, amsterdam)},
+	}
+	if len(departures) != len(expected) {
+		t.Fatalf("expected %d departures, got %+v", len(expected), departures)
+	}
+	for i, departure := range departures {
+		e := expected[i]
+		if departure.TripId != e.trip || departure.Headsign != e.headsign || departure.PlatformCode != e.platform || !departure.Time.Equal(e.time) {
+			t.Errorf("departure %d: expected %+v, got %+v", i, e, departure)
+		}
+	}
+	if departures[1].PickupType != PickupAskDriver || departures[1].RouteShortName != "5" {
+		t.Errorf("unexpected pickup type or route of %+v", departures[1])
+	}
+
+	if departures, _ := feed.Departures("p1", at, 3, false); len(departures) != 3 || departures[0].TripId != "morning" {
+		t.Errorf("expected only departures from p1, got %+v", departures)
+	}
+	if departures, _ := feed.Departures("end", at, 3, false); len(departures) != 0 {
+		t.Errorf("expected no departures from the last stop, got %+v", departures)
+	}
+	if _, err := feed.Departures("missing", at, 3, false); err == nil {
+		t.Error("expected an error for an unknown stop")
+	}
+	for _, count := range []int{0, -1} {
+		if _, err := feed.Departures("p1", at, count, false); !errors.Is(err, ErrInvalidCount) {
+			t.Errorf("expected an invalid count error for %d, got %v", count, err)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/util"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// actions of a Change
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+	ChangeMoved    = "moved" // a stop of which only the coordinates changed
+)
+
+// Change describes how one agency, route, stop, trip or service differs between two versions of a feed
+type Change struct {
+	Action   string        `json:"action"`
+	Fields   []FieldChange `json:"fields,omitempty"`
+	Distance float64       `json:"distance,omitempty"` // meters a stop moved
+
+	// dates a service started or stopped running on
+	AddedDates   []string `json:"added_dates,omitempty"`
+	RemovedDates []string `json:"removed_dates,omitempty"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"` // column name, or stop_times for the stop times of a trip
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// FeedDiff holds the changes between two feeds per kind of entity, keyed by ID
+type FeedDiff struct {
+	Agencies map[string]Change `json:"agencies"`
+	Routes   map[string]Change `json:"routes"`
+	Stops    map[string]Change `json:"stops"`
+	Trips    map[string]Change `json:"trips"`
+	Services map[string]Change `json:"services"`
+}
+
+type DiffOptions struct {
+	MoveThreshold float64 // meters a stop has to move before its coordinates count as changed
+}
+
+// Diff compares two versions of a feed
+func Diff(old, updated *Store, options DiffOptions) (*FeedDiff, error) {
+	diff := &FeedDiff{}
+
+	oldRows, updatedRows := make([]keyedRow, 0, len(old.Agency)), make([]keyedRow, 0, len(updated.Agency))
+	for _, agency := range old.Agency {
+		oldRows = append(oldRows, keyedRow{agency.Id, agency})
+	}
+	for _, agency := range updated.Agency {
+		updatedRows = append(updatedRows, keyedRow{agency.Id, agency})
+	}
+	diff.Agencies = diffRows(oldRows, updatedRows, compareFields)
+
+	oldRows, updatedRows = make([]keyedRow, 0, len(old.Route)), make([]keyedRow, 0, len(updated.Route))
+	for _, route := range old.Route {
+		oldRows = append(oldRows, keyedRow{route.RouteId, route})
+	}
+	for _, route := range updated.Route {
+		updatedRows = append(updatedRows, keyedRow{route.RouteId, route})
+	}
+	diff.Routes = diffRows(oldRows, updatedRows, compareFields)
+
+	oldRows, updatedRows = make([]keyedRow, 0, len(old.Stop)), make([]keyedRow, 0, len(updated.Stop))
+	for _, stop := range old.Stop {
+		oldRows = append(oldRows, keyedRow{stop.Id, stop})
+	}
+	for _, stop := range updated.Stop {
+		updatedRows = append(updatedRows, keyedRow{stop.Id, stop})
+	}
+	diff.Stops = diffRows(oldRows, updatedRows, func(a, b interface{}) (Change, bool) {
+		oldStop, updatedStop := a.(Stop), b.(Stop)
+		moved := distance(oldStop.Lat, oldStop.Lon, updatedStop.Lat, updatedStop.Lon)
+		if moved <= options.MoveThreshold {
+			updatedStop.Lat, updatedStop.Lon = oldStop.Lat, oldStop.Lon // small corrections of coordinates aren't changes
+			return compareFields(oldStop, updatedStop)
+		}
+
+		change, _ := compareFields(oldStop, updatedStop)
+		change.Distance = moved
+		if onlyCoordinates(change.Fields) {
+			change.Action = ChangeMoved
+		}
+		return change, true
+	})
+
+	_, oldStopTimes := stopTimesByTrip(old.StopTime)
+	_, updatedStopTimes := stopTimesByTrip(updated.StopTime)
+	oldRows, updatedRows = make([]keyedRow, 0, len(old.Trip)), make([]keyedRow, 0, len(updated.Trip))
+	for _, trip := range old.Trip {
+		oldRows = append(oldRows, keyedRow{trip.TripId, trip})
+	}
+	for _, trip := range updated.Trip {
+		updatedRows = append(updatedRows, keyedRow{trip.TripId, trip})
+	}
+	diff.Trips = diffRows(oldRows, updatedRows, func(a, b interface{}) (Change, bool) {
+		change, changed := compareFields(a, b)
+		tripId := a.(Trip).TripId
+		if !sameStopTimes(oldStopTimes[tripId], updatedStopTimes[tripId]) {
+			change.Fields = append(change.Fields, FieldChange{Field: "stop_times", Old: describeStopTimes(oldStopTimes[tripId]), New: describeStopTimes(updatedStopTimes[tripId])})
+			changed = true
+		}
+		return change, changed
+	})
+
+	services, err := diffServices(old, updated)
+	if err != nil {
+		return nil, err
+	}
+	diff.Services = services
+	return diff, nil
+}
+
+// keyedRow is a row of any table together with its ID
+type keyedRow struct {
+	id  string
+	row interface{}
+}
+
+// diffRows matches the rows of two versions of a table by ID. compare tells how two rows with the same ID differ.
+func diffRows(old, updated []keyedRow, compare func(a, b interface{}) (Change, bool)) map[string]Change {
+	changes := map[string]Change{}
+	oldById := make(map[string]interface{}, len(old))
+	for _, row := range old {
+		oldById[row.id] = row.row
+	}
+
+	seen := make(map[string]bool, len(updated))
+	for _, row := range updated {
+		seen[row.id] = true
+		oldRow, ok := oldById[row.id]
+		if !ok {
+			changes[row.id] = Change{Action: ChangeAdded}
+		} else if change, changed := compare(oldRow, row.row); changed {
+			changes[row.id] = change
+		}
+	}
+	for id := range oldById {
+		if !seen[id] {
+			changes[id] = Change{Action: ChangeRemoved}
+		}
+	}
+	return changes
+}
+
+// compareFields compares two rows of the same table field by field
+func compareFields(a, b interface{}) (Change, bool) {
+	fields := fieldChanges(a, b)
+	return Change{Action: ChangeModified, Fields: fields}, len(fields) > 0
+}
+
+// fieldChanges lists the fields that differ between two rows of the same table
+func fieldChanges(a, b interface{}) []FieldChange {
+	var changes []FieldChange
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		column := va.Type().Field(i).Tag.Get("gtfs")
+		if column == "" {
+			continue // the raw text a row was read with isn't a value of its own
+		}
+		fa, fb := va.Field(i).Interface(), vb.Field(i).Interface()
+		if fa != fb {
+			changes = append(changes, FieldChange{Field: column, Old: formatValue(fa), New: formatValue(fb)})
+		}
+	}
+	return changes
+}
+
+func onlyCoordinates(fields []FieldChange) bool {
+	for _, field := range fields {
+		if field.Field != "stop_lat" && field.Field != "stop_lon" {
+			return false
+		}
+	}
+	return true
+}
+
+// describeStopTimes summarises the stop times of a trip as its number of stops, first and last stop and times
+func describeStopTimes(stopTimes []StopTime) string {
+	if len(stopTimes) == 0 {
+		return "no stops"
+	}
+	first, last := stopTimes[0], stopTimes[len(stopTimes)-1]
+	return fmt.Sprintf("%d stops, %s %s - %s %s", len(stopTimes), first.StopId, first.DepartureTime, last.StopId, last.ArrivalTime)
+}
+
+// diffServices compares the dates each service runs on
+func diffServices(old, updated *Store) (map[string]Change, error) {
+	oldCalendar, err := NewServiceCalendar(old)
+	if err != nil {
+		return nil, err
+	}
+	updatedCalendar, err := NewServiceCalendar(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	oldServices, updatedServices := util.StringSet{}, util.StringSet{}
+	for _, serviceId := range oldCalendar.Services() {
+		oldServices.Add(serviceId)
+	}
+	for _, serviceId := range updatedCalendar.Services() {
+		updatedServices.Add(serviceId)
+	}
+
+	changes := map[string]Change{}
+	for _, serviceId := range oldCalendar.Services() {
+		if !updatedServices.Has(serviceId) {
+			changes[serviceId] = Change{Action: ChangeRemoved, RemovedDates: formatDays(oldCalendar.serviceDays(serviceId))}
+		}
+	}
+
+	for _, serviceId := range updatedCalendar.Services() {
+		oldDays := map[int]bool{}
+		for _, day := range oldCalendar.serviceDays(serviceId) {
+			oldDays[day] = true
+		}
+
+		change := Change{Action: ChangeModified}
+		if !oldServices.Has(serviceId) {
+			change.Action = ChangeAdded
+		}
+		for _, day := range updatedCalendar.serviceDays(serviceId) {
+			if oldDays[day] {
+				delete(oldDays, day)
+			} else {
+				change.AddedDates = append(change.AddedDates, FormatDate(dayDate(day)))
+			}
+		}
+		removed := make([]int, 0, len(oldDays))
+		for day := range oldDays {
+			removed = append(removed, day)
+		}
+		sort.Ints(removed)
+		change.RemovedDates = formatDays(removed)
+
+		if change.Action == ChangeAdded || len(change.AddedDates) > 0 || len(change.RemovedDates) > 0 {
+			changes[serviceId] = change
+		}
+	}
+	return changes, nil
+}
+
+func formatDays(days []int) []string {
+	dates := make([]string, 0, len(days))
+	for _, day := range days {
+		dates = append(dates, FormatDate(dayDate(day)))
+	}
+	return dates
+}
+
+// changes shown per kind of entity in the text summary
+const diffTextChangesPerKind = 20
+
+// WriteText writes a human readable summary of the changes
+func (diff *FeedDiff) WriteText(w io.Writer) error {
+	b := &strings.Builder{}
+	kinds := []struct {
+		name, title string
+		changes     map[string]Change
+	}{
+		{"agencies", "Agencies", diff.Agencies},
+		{"routes", "Routes", diff.Routes},
+		{"stops", "Stops", diff.Stops},
+		{"trips", "Trips", diff.Trips},
+		{"services", "Services", diff.Services},
+	}
+
+	for _, kind := range kinds {
+		counts := map[string]int{}
+		for _, change := range kind.changes {
+			counts[change.Action]++
+		}
+		fmt.Fprintf(b, "%-9s %d added, %d removed, %d modified", kind.name+":", counts[ChangeAdded], counts[ChangeRemoved], counts[ChangeModified])
+		if counts[ChangeMoved] > 0 {
+			fmt.Fprintf(b, ", %d moved", counts[ChangeMoved])
+		}
+		b.WriteString("\n")
+	}
+
+	for _, kind := range kinds {
+		if len(kind.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n%s\n", kind.title)
+
+		ids := make([]string, 0, len(kind.changes))
+		for id := range kind.changes {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for i, id := range ids {
+			if i == diffTextChangesPerKind {
+				fmt.Fprintf(b, "  ... and %d more\n", len(ids)-diffTextChangesPerKind)
+				break
+			}
+			fmt.Fprintf(b, "  %-8s %s%s\n", kind.changes[id].Action, id, kind.changes[id].describe())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// describe summarises the details of a change on one line
+func (change Change) describe() string {
+	details := make([]string, 0)
+	for _, field := range change.Fields {
+		details = append(details, fmt.Sprintf("%s %q -> %q", field.Field, field.Old, field.New))
+	}
+	if change.Distance > 0 {
+		details = append(details, fmt.Sprintf("moved %.0f m", change.Distance))
+	}
+	if len(change.AddedDates) > 0 {
+		details = append(details, fmt.Sprintf("%d dates added (%s)", len(change.AddedDates), summariseDates(change.AddedDates)))
+	}
+	if len(change.RemovedDates) > 0 {
+		details = append(details, fmt.Sprintf("%d dates removed (%s)", len(change.RemovedDates), summariseDates(change.RemovedDates)))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(details, ", ")
+}
+
+func summariseDates(dates []string) string {
+	if len(dates) == 1 {
+		return dates[0]
+	}
+	return dates[0] + " to " + dates[len(dates)-1]
+}
+
+// WriteJSON writes the changes keyed by entity ID
+func (diff *FeedDiff) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diff)
+}
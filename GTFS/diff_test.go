@@ -0,0 +1,84 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := testStore()
+	updated := testStore()
+	updated.Stop[0].Lat += 0.01       // about a kilometer
+	updated.Stop[1].Lon += 0.000001   // a few centimeters
+	updated.Route = updated.Route[:1] // r2 removed
+	updated.Trip[0].TripHeadsign = "Zuid via Centrum"
+	updated.StopTime[1].DepartureTime = NewTime(25, 15, 0)
+	updated.Calendar[1].EndDate = "20261130"
+
+	diff, err := Diff(&old, &updated, DiffOptions{MoveThreshold: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change := diff.Stops["a"]; change.Action != ChangeMoved || change.Distance < 1000 || change.Distance > 1200 {
+		t.Errorf("expected stop a to have moved about a kilometer, got %+v", change)
+	}
+	if _, ok := diff.Stops["b"]; ok {
+		t.Errorf("expected stop b to be unchanged, got %+v", diff.Stops["b"])
+	}
+	if diff.Routes["r2"].Action != ChangeRemoved || len(diff.Routes) != 1 {
+		t.Errorf("expected only route r2 to be removed, got %+v", diff.Routes)
+	}
+	if fields := diff.Trips["t1"].Fields; len(fields) != 2 || fields[0].Field != "trip_headsign" || fields[1].Field != "stop_times" {
+		t.Errorf("expected the headsign and stop times of t1 to be modified, got %+v", diff.Trips)
+	}
+	if change := diff.Services["s,2"]; len(diff.Services) != 1 || len(change.AddedDates) != 0 || len(change.RemovedDates) != 8 || change.RemovedDates[0] != "20261205" {
+		t.Errorf("expected the December weekends of s,2 to be removed, got %+v", diff.Services)
+	}
+}
+
+func TestDiffWriters(t *testing.T) {
+	old := testStore()
+	updated := testStore()
+	updated.Stop[0].Lat += 0.01
+	updated.Route = updated.Route[:1]
+	updated.Trip[0].TripHeadsign = "Zuid via Centrum"
+
+	diff, err := Diff(&old, &updated, DiffOptions{MoveThreshold: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := &strings.Builder{}
+	if err := diff.WriteText(text); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"routes:   0 added, 1 removed, 0 modified\n",
+		"stops:    0 added, 0 removed, 0 modified, 1 moved\n",
+		"  removed  r2\n",
+		`  modified t1: trip_headsign "Zuid" -> "Zuid via Centrum"` + "\n",
+		`  moved    a: stop_lat "52.378" -> "52.388", moved 1112 m` + "\n",
+	} {
+		if !strings.Contains(text.String(), line) {
+			t.Errorf("text summary is missing %q:\n%s", line, text)
+		}
+	}
+
+	out := &strings.Builder{}
+	if err := diff.WriteJSON(out); err != nil {
+		t.Fatal(err)
+	}
+	decoded := FeedDiff{}
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := FieldChange{Field: "trip_headsign", Old: "Zuid", New: "Zuid via Centrum"}
+	if fields := decoded.Trips["t1"].Fields; len(fields) != 1 || fields[0] != expected {
+		t.Errorf("expected the headsign change of t1 in JSON, got %+v", decoded.Trips)
+	}
+	if decoded.Routes["r2"].Action != ChangeRemoved || decoded.Stops["a"].Action != ChangeMoved {
+		t.Errorf("unexpected JSON diff:\n%s", out)
+	}
+}
@@ -0,0 +1,333 @@
+package GTFS
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/util"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export writes the store as a feed to exportName, which is a directory or, when the name ends in .zip, a zip archive
+func (store *Store) Export(exportName string) error {
+	if strings.HasSuffix(strings.ToLower(exportName), ".zip") {
+		archiveFile, err := os.Create(exportName)
+		if err != nil {
+			return err
+		}
+		if err := store.ExportZip(archiveFile); err != nil {
+			archiveFile.Close()
+			return err
+		}
+		return archiveFile.Close()
+	}
+
+	if err := os.MkdirAll(exportName, 0755); err != nil {
+		return err
+	}
+	return store.export(func(fileName string) (io.WriteCloser, error) {
+		return os.Create(filepath.Join(exportName, fileName))
+	})
+}
+
+// ExportZip streams the store as a zip archive holding one file per table to w
+func (store *Store) ExportZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	modified := time.Now()
+
+	err := store.export(func(fileName string) (io.WriteCloser, error) {
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: fileName, Method: zip.Deflate, Modified: modified})
+		return nopCloser{entry}, err
+	})
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// tableWriter writes the rows of one exported file as RFC 4180 CSV, quoting values that contain commas, quotes or line breaks
+type tableWriter struct {
+	file    io.WriteCloser
+	writer  *csv.Writer
+	columns []string
+	fields  []string
+}
+
+func newTableWriter(create func(fileName string) (io.WriteCloser, error), fileName string, columns []string) (*tableWriter, error) {
+	file, err := create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &tableWriter{file: file, writer: csv.NewWriter(file), columns: columns, fields: make([]string, len(columns))}
+	table.writer.Write(columns)
+	return table, nil
+}
+
+// row writes values, one for each column, copying the text a value was read with from raw when it still reads as that
+// value, so numbers and times are written as they were read and optional values left empty stay empty
+func (table *tableWriter) row(values []interface{}, raw rawValues) {
+	table.fields = table.fields[:0]
+	for i, value := range values {
+		if text, ok := raw.get(table.columns[i]); ok && readsAs(text, value) {
+			table.fields = append(table.fields, text)
+		} else {
+			table.fields = append(table.fields, formatValue(value))
+		}
+	}
+	table.writer.Write(table.fields)
+}
+
+// readsAs tells whether text is read as value, so a value changed after reading is written anew
+func readsAs(text string, value interface{}) bool {
+	text = strings.TrimSpace(text)
+	switch v := value.(type) {
+	case int:
+		i, err := strconv.Atoi(text)
+		return (text == "" || err == nil) && i == v
+	case float64:
+		f, err := strconv.ParseFloat(text, 64)
+		return (text == "" || err == nil) && f == v
+	case Time:
+		t, err := ParseTime(text)
+		return err == nil && t == v
+	default:
+		return false
+	}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return util.ParseString(v)
+	case float64:
+		return util.ParseFloatString(v)
+	case Time:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (table *tableWriter) close() error {
+	table.writer.Flush()
+	if err := table.writer.Error(); err != nil {
+		table.file.Close()
+		return err
+	}
+	return table.file.Close()
+}
+
+func (store *Store) export(create func(fileName string) (io.WriteCloser, error)) error {
+	stopsFile, err := newTableWriter(create, "stops.txt", []string{"stop_id", "stop_code", "stop_name", "stop_lat", "stop_lon", "location_type", "parent_station", "stop_timezone", "wheelchair_boarding", "platform_code", "zone_id"})
+	if err != nil {
+		return err
+	}
+	for _, stop := range store.Stop {
+		var row = []interface{}{}
+		row = append(row, stop.Id)
+		row = append(row, stop.Code)
+		row = append(row, stop.Name)
+		row = append(row, stop.Lat)
+		row = append(row, stop.Lon)
+		row = append(row, stop.LocationType)
+		row = append(row, stop.ParentStation)
+		row = append(row, stop.StopTimezone)
+		row = append(row, stop.WheelchairBoarding)
+		row = append(row, stop.PlatformCode)
+		row = append(row, stop.ZoneId)
+
+		stopsFile.row(row, stop.raw)
+	}
+	if err := stopsFile.close(); err != nil {
+		return err
+	}
+
+	agencyFile, err := newTableWriter(create, "agency.txt", []string{"agency_id", "agency_name", "agency_url", "agency_timezone", "agency_phone"})
+	if err != nil {
+		return err
+	}
+	for _, agency := range store.Agency {
+		var row = []interface{}{}
+		row = append(row, agency.Id)
+		row = append(row, agency.Name)
+		row = append(row, agency.URL)
+		row = append(row, agency.Timezone)
+		row = append(row, agency.Phone)
+
+		agencyFile.row(row, "")
+	}
+	if err := agencyFile.close(); err != nil {
+		return err
+	}
+
+	if len(store.Calendar) > 0 { // calendar.txt is optional, feeds that only use calendar_dates.txt are exported without it
+		calendarFile, err := newTableWriter(create, "calendar.txt", []string{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"})
+		if err != nil {
+			return err
+		}
+		for _, calendar := range store.Calendar {
+			var row = []interface{}{}
+			row = append(row, calendar.ServiceId)
+			row = append(row, calendar.Monday)
+			row = append(row, calendar.Tuesday)
+			row = append(row, calendar.Wednesday)
+			row = append(row, calendar.Thursday)
+			row = append(row, calendar.Friday)
+			row = append(row, calendar.Saturday)
+			row = append(row, calendar.Sunday)
+			row = append(row, calendar.StartDate)
+			row = append(row, calendar.EndDate)
+
+			calendarFile.row(row, calendar.raw)
+		}
+		if err := calendarFile.close(); err != nil {
+			return err
+		}
+	}
+
+	calendarDatesFile, err := newTableWriter(create, "calendar_dates.txt", []string{"service_id", "date", "exception_type"})
+	if err != nil {
+		return err
+	}
+	for _, calendarDate := range store.CalendarDates {
+		var row = []interface{}{}
+		row = append(row, calendarDate.ServiceId)
+		row = append(row, calendarDate.Date)
+		row = append(row, calendarDate.ExceptionType)
+
+		calendarDatesFile.row(row, calendarDate.raw)
+	}
+	if err := calendarDatesFile.close(); err != nil {
+		return err
+	}
+
+	routesFile, err := newTableWriter(create, "routes.txt", []string{"route_id", "agency_id", "external_code", "route_short_name", "route_long_name", "route_desc", "route_type", "route_color", "route_text_color", "route_url"})
+	if err != nil {
+		return err
+	}
+	for _, route := range store.Route {
+		var row = []interface{}{}
+		row = append(row, route.RouteId)
+		row = append(row, route.AgencyId)
+		row = append(row, route.ExternalCode)
+		row = append(row, route.RouteShortName)
+		row = append(row, route.RouteLongName)
+		row = append(row, route.RouteDesc)
+		row = append(row, route.RouteType)
+		row = append(row, route.RouteColor)
+		row = append(row, route.RouteTextColor)
+		row = append(row, route.RouteURL)
+
+		routesFile.row(row, "")
+	}
+	if err := routesFile.close(); err != nil {
+		return err
+	}
+
+	shapesFile, err := newTableWriter(create, "shapes.txt", []string{"shape_id", "shape_pt_sequence", "shape_pt_lat", "shape_pt_lon", "shape_dist_traveled"})
+	if err != nil {
+		return err
+	}
+	for _, shape := range store.Shape {
+		var row = []interface{}{}
+		row = append(row, shape.Id)
+		row = append(row, shape.PTSequence)
+		row = append(row, shape.Lat)
+		row = append(row, shape.Lon)
+		row = append(row, shape.DistTraveled)
+
+		shapesFile.row(row, shape.raw)
+	}
+	if err := shapesFile.close(); err != nil {
+		return err
+	}
+
+	stopTimesFile, err := newTableWriter(create, "stop_times.txt", []string{"trip_id", "stop_sequence", "stop_id", "stop_headsign", "arrival_time", "departure_time", "pickup_type", "drop_off_type", "timepoint", "shape_dist_traveled", "fare_units_traveled"})
+	if err != nil {
+		return err
+	}
+	for _, stopTime := range store.StopTime {
+		var row = []interface{}{}
+		row = append(row, stopTime.TripId)
+		row = append(row, stopTime.Sequence)
+		row = append(row, stopTime.StopId)
+		row = append(row, stopTime.StopHeadsign)
+		row = append(row, stopTime.ArrivalTime)
+		row = append(row, stopTime.DepartureTime)
+		row = append(row, stopTime.PickUpType)
+		row = append(row, stopTime.DropOffType)
+		row = append(row, stopTime.Timepoint)
+		row = append(row, stopTime.ShapeDistTraveled)
+		row = append(row, stopTime.FareUnitsTraveled)
+
+		stopTimesFile.row(row, stopTime.raw)
+	}
+	if err := stopTimesFile.close(); err != nil {
+		return err
+	}
+
+	transfersFile, err := newTableWriter(create, "transfers.txt", []string{"from_stop_id", "to_stop_id", "from_route_id", "to_route_id", "from_trip_id", "to_trip_id", "transfer_type", "min_transfer_time"})
+	if err != nil {
+		return err
+	}
+	for _, transfer := range store.Transfer {
+		var row = []interface{}{}
+		row = append(row, transfer.FromStopId)
+		row = append(row, transfer.ToStopId)
+		row = append(row, transfer.FromRouteId)
+		row = append(row, transfer.ToRouteId)
+		row = append(row, transfer.FromTripId)
+		row = append(row, transfer.ToTripId)
+		row = append(row, transfer.TransferType)
+		row = append(row, transfer.MinTransferTime)
+
+		transfersFile.row(row, transfer.raw)
+	}
+	if err := transfersFile.close(); err != nil {
+		return err
+	}
+
+	tripsFile, err := newTableWriter(create, "trips.txt", []string{"route_id", "service_id", "trip_id", "realtime_trip_id", "trip_headsign", "trip_short_name", "trip_long_name", "direction_id", "block_id", "shape_id", "wheelchair_accessible", "bikes_allowed"})
+	if err != nil {
+		return err
+	}
+	for _, trip := range store.Trip {
+		var row = []interface{}{}
+		row = append(row, trip.RouteId)
+		row = append(row, trip.ServiceId)
+		row = append(row, trip.TripId)
+		row = append(row, trip.RealtimeTripId)
+		row = append(row, trip.TripHeadsign)
+		row = append(row, trip.TripShortName)
+		row = append(row, trip.TripLongName)
+		row = append(row, trip.DirectionId)
+		row = append(row, trip.BlockId)
+		row = append(row, trip.ShapeId)
+		row = append(row, trip.WheelchairAccessible)
+		row = append(row, trip.BikesAllowed)
+
+		tripsFile.row(row, trip.raw)
+	}
+	if err := tripsFile.close(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package GTFS
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testStore holds two rows of every table; the first row sets every field, the second contains values that need quoting
+func testStore() Store {
+	return Store{
+		Agency: []Agency{
+			{Id: "TB", Name: "Test Bus", URL: "https://bus.example", Timezone: "Europe/Amsterdam", Phone: "+31 20 123 4567"},
+			{Id: "TT", Name: `Tram "Tours", Inc.`, URL: "https://tram.example", Timezone: "Europe/Amsterdam", Phone: ""},
+		},
+		Calendar: []Calendar{
+			{ServiceId: "s1", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, Saturday: 1, Sunday: 1, StartDate: "20261001", EndDate: "20261231"},
+			{ServiceId: "s,2", Saturday: 1, Sunday: 1, StartDate: "20261001", EndDate: "20261231"},
+		},
+		CalendarDates: []CalendarDate{
+			{ServiceId: "s1", Date: "20261019", ExceptionType: 1},
+			{ServiceId: "s,2", Date: "20261020", ExceptionType: 2},
+		},
+		Route: []Route{
+			{RouteId: "r1", AgencyId: "TB", ExternalCode: "ext:1", RouteShortName: "1", RouteLongName: "Centraal - Zuid", RouteDesc: "Line\nwith a break", RouteType: "3", RouteColor: "FF0000", RouteTextColor: "FFFFFF", RouteURL: "https://bus.example/1"},
+			{RouteId: "r2", AgencyId: "TT", RouteShortName: " 2", RouteType: "0"},
+		},
+		Shape: []Shape{
+			{Id: "sh1", PTSequence: 1, Lat: 52.3791234, Lon: 4.9004567, DistTraveled: 12.5},
+			{Id: "sh1", PTSequence: 2, Lat: -33.8688197, Lon: 151.2092955, DistTraveled: 1234567},
+		},
+		StopTime: []StopTime{
+			{TripId: "t1", Sequence: 1, StopId: "a", StopHeadsign: "Zuid", ArrivalTime: NewTime(8, 0, 0), DepartureTime: NewTime(8, 1, 0), PickUpType: 1, DropOffType: 2, Timepoint: 1, ShapeDistTraveled: 0.5, FareUnitsTraveled: 3},
+			{TripId: "t1", Sequence: 2, StopId: "b", StopHeadsign: `"Zuid"`, ArrivalTime: NoTime, DepartureTime: NewTime(25, 13, 0)},
+		},
+		Stop: []Stop{
+			{Id: "a", Code: "1001", Name: "Centraal", Lat: 52.378, Lon: 4.9, LocationType: 1, ParentStation: "station", StopTimezone: "Europe/Amsterdam", WheelchairBoarding: 1, PlatformCode: "1a", ZoneId: "z1"},
+			{Id: "b", Name: "Stop \"B\",\nNoord", Lat: 52.4, Lon: 4.95},
+		},
+		Transfer: []Transfer{
+			{FromStopId: "a", ToStopId: "b", FromRouteId: "r1", ToRouteId: "r2", FromTripId: "t1", ToTripId: "t2", TransferType: 2, MinTransferTime: 180},
+			{FromStopId: "b", ToStopId: "a", TransferType: 0},
+		},
+		Trip: []Trip{
+			{RouteId: "r1", ServiceId: "s1", TripId: "t1", RealtimeTripId: "rt1", TripHeadsign: "Zuid", TripShortName: "1001", TripLongName: "Sprinter", DirectionId: 1, BlockId: "b1", ShapeId: "sh1", WheelchairAccessible: 1, BikesAllowed: 2},
+			{RouteId: "r2", ServiceId: "s,2", TripId: "t2", TripHeadsign: "Noord, via \"Centrum\""},
+		},
+	}
+}
+
+// TestStoreFieldsCovered makes sure the first row of every table in testStore sets every field, so a field that is added
+// to a table but not to ReadFile or Export makes the round trip tests fail
+func TestStoreFieldsCovered(t *testing.T) {
+	store := reflect.ValueOf(testStore())
+	for _, fileType := range FileTypes {
+		table := store.FieldByName(tableFields[fileType])
+		if table.Len() == 0 {
+			t.Fatalf("%s: no rows in test store", fileType)
+		}
+
+		row := table.Index(0)
+		for i := 0; i < row.NumField(); i++ {
+			if row.Type().Field(i).PkgPath != "" {
+				continue // unexported fields aren't columns
+			}
+			if row.Field(i).IsZero() {
+				t.Errorf("%s: field %s is not set in the first row", fileType, row.Type().Field(i).Name)
+			}
+			if row.Type().Field(i).Tag.Get("gtfs") == "" {
+				t.Errorf("%s: field %s has no gtfs tag naming its column", fileType, row.Type().Field(i).Name)
+			}
+		}
+	}
+}
+
+// the Store field holding the rows of each file type
+var tableFields = map[string]string{
+	"agency":         "Agency",
+	"calendar":       "Calendar",
+	"calendar_dates": "CalendarDates",
+	"routes":         "Route",
+	"shapes":         "Shape",
+	"stop_times":     "StopTime",
+	"stops":          "Stop",
+	"transfers":      "Transfer",
+	"trips":          "Trip",
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	expected := testStore()
+	dir := filepath.Join(t.TempDir(), "feed")
+	if err := expected.Export(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := Store{}
+	if err := actual.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	assertStoresEqual(t, expected, actual)
+}
+
+func TestExportZipRoundTrip(t *testing.T) {
+	expected := testStore()
+	buffer := &bytes.Buffer{}
+	if err := expected.ExportZip(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := Store{}
+	if err := actual.LoadZip(bytes.NewReader(buffer.Bytes()), int64(buffer.Len())); err != nil {
+		t.Fatal(err)
+	}
+	assertStoresEqual(t, expected, actual)
+}
+
+func TestExportIsStable(t *testing.T) {
+	first := filepath.Join(t.TempDir(), "first")
+	store := testStore()
+	if err := store.Export(first); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := Store{}
+	if err := reloaded.Load(first); err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(t.TempDir(), "second")
+	if err := reloaded.Export(second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fileType := range FileTypes {
+		a, err := os.ReadFile(filepath.Join(first, fileType+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(filepath.Join(second, fileType+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s.txt changed after a round trip:\n%s\n%s", fileType, a, b)
+		}
+	}
+}
+
+func TestExportKeepsValuesAsRead(t *testing.T) {
+	// optional numbers left empty, numbers with trailing zeros and times without a leading zero are written back as read
+	files := map[string]string{
+		"agency": "agency_id,agency_name,agency_url,agency_timezone,agency_phone\n" +
+			"A,Agency,https://a.example,Europe/Amsterdam,\n",
+		"calendar": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+			"s,1,1,1,1,1,0,0,20261001,20261231\n",
+		"calendar_dates": "service_id,date,exception_type\n" +
+			"s,20261019,02\n",
+		"routes": "route_id,agency_id,external_code,route_short_name,route_long_name,route_desc,route_type,route_color,route_text_color,route_url\n" +
+			"r,A,,5,,,3,,,\n",
+		"shapes": "shape_id,shape_pt_sequence,shape_pt_lat,shape_pt_lon,shape_dist_traveled\n" +
+			"sh,1,52.3700,4.9000,0\n" +
+			"sh,2,52.38,4.9,\n",
+		"stop_times": "trip_id,stop_sequence,stop_id,stop_headsign,arrival_time,departure_time,pickup_type,drop_off_type,timepoint,shape_dist_traveled,fare_units_traveled\n" +
+			"t,1,a,,8:00:00,8:00:00,,,,0.0,\n" +
+			"t,2,b,,08:05:00,08:05:00,0,1,0,,\n" +
+			"t,3,n,,,,,,,,\n",
+		"stops": "stop_id,stop_code,stop_name,stop_lat,stop_lon,location_type,parent_station,stop_timezone,wheelchair_boarding,platform_code,zone_id\n" +
+			"a,,A,52.37,4.9,,,,,,\n" +
+			"b,,B,52.380,4.90,0,,,0,,\n" +
+			"n,,Node,,,3,a,,,,\n",
+		"transfers": "from_stop_id,to_stop_id,from_route_id,to_route_id,from_trip_id,to_trip_id,transfer_type,min_transfer_time\n" +
+			"a,b,,,,,2,\n" +
+			"b,a,,,,,,120\n",
+		"trips": "route_id,service_id,trip_id,realtime_trip_id,trip_headsign,trip_short_name,trip_long_name,direction_id,block_id,shape_id,wheelchair_accessible,bikes_allowed\n" +
+			"r,s,t,,,,,,,sh,,\n",
+	}
+	dir := t.TempDir()
+	for fileType, content := range files {
+		writeFile(t, dir, fileType+".txt", content)
+	}
+
+	store := Store{}
+	if err := store.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if !store.StopTime[0].HasShapeDistTraveled() || store.StopTime[1].HasShapeDistTraveled() || store.Stop[2].HasCoordinates() {
+		t.Error("expected empty shape_dist_traveled and coordinates to be told apart from 0")
+	}
+
+	// a value changed after reading is written anew
+	store.Trip[0].DirectionId = 1
+	files["trips"] = strings.Replace(files["trips"], "r,s,t,,,,,,", "r,s,t,,,,,1,", 1)
+
+	exported := filepath.Join(t.TempDir(), "feed")
+	if err := store.Export(exported); err != nil {
+		t.Fatal(err)
+	}
+	for fileType, content := range files {
+		written, err := os.ReadFile(filepath.Join(exported, fileType+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(written) != content {
+			t.Errorf("%s.txt changed:\n%s\nexpected:\n%s", fileType, written, content)
+		}
+	}
+}
+
+func TestReadFileHeaderOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "stops.txt", "\uFEFFstop_name,stop_lon,stop_lat,stop_id\nCentraal,4.9,52.378,a\nShort row\n")
+
+	store := Store{}
+	if err := store.ReadFile("stops", filepath.Join(dir, "stops.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Stop{
+		{Id: "a", Name: "Centraal", Lat: 52.378, Lon: 4.9, raw: ";location_type=;wheelchair_boarding="},
+		{Name: "Short row", raw: ";stop_lat=;stop_lon=;location_type=;wheelchair_boarding="},
+	}
+	if !reflect.DeepEqual(store.Stop, expected) {
+		t.Errorf("got %+v, expected %+v", store.Stop, expected)
+	}
+}
+
+func TestReadFileMissingColumn(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "trips.txt", "route_id,trip_id\nr1,t1\n")
+
+	store := Store{}
+	err := store.ReadFile("trips", filepath.Join(dir, "trips.txt"))
+	if !errors.Is(err, ErrMissingColumn) {
+		t.Fatalf("expected missing column error, got %v", err)
+	}
+
+	var parseError *ParseError
+	if !errors.As(err, &parseError) || parseError.Column != "service_id" || parseError.Line != 1 {
+		t.Errorf("unexpected error %#v", err)
+	}
+}
+
+func TestReadFileInvalidValue(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "shapes.txt", "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\nsh1,52.1,4.9,1\nsh1,north,4.9,2\n")
+
+	store := Store{}
+	err := store.ReadFile("shapes", filepath.Join(dir, "shapes.txt"))
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+	if parseError.Line != 3 || parseError.Column != "shape_pt_lat" || parseError.Value != "north" || !errors.Is(err, ErrInvalidFloat) {
+		t.Errorf("unexpected error %#v", parseError)
+	}
+
+	lenient := Store{Lenient: true}
+	if err := lenient.ReadFile("shapes", filepath.Join(dir, "shapes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if len(lenient.Shape) != 2 || len(lenient.Warnings) != 1 || lenient.Warnings[0].Line != 3 {
+		t.Errorf("expected 2 shapes and 1 warning, got %+v and %v", lenient.Shape, lenient.Warnings)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertStoresEqual(t *testing.T, expected, actual Store) {
+	t.Helper()
+	expectedValue, actualValue := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	for _, fileType := range FileTypes {
+		field := tableFields[fileType]
+		if !reflect.DeepEqual(expectedValue.FieldByName(field).Interface(), actualValue.FieldByName(field).Interface()) {
+			t.Errorf("%s differs after round trip:\nexpected %+v\ngot      %+v", fileType, expectedValue.FieldByName(field).Interface(), actualValue.FieldByName(field).Interface())
+		}
+	}
+}
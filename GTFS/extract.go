@@ -0,0 +1,518 @@
+package GTFS
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/util"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrEmptyFilter = errors.New("filter does not select anything")
+
+// Filter describes which part of a feed to extract. A route is extracted when it matches every kind of selector that
+// is set; within one kind of selector, matching any of the values is enough.
+type Filter struct {
+	Agencies        []string       // IDs or glob patterns (like "ARR*") of the agencies whose routes are kept
+	AgencyPattern   *regexp.Regexp // regular expression matching the IDs of further agencies to keep
+	Routes          []string       // IDs or glob patterns of the routes to keep
+	RouteTypes      []string       // route_type values of the routes to keep
+	RouteShortNames []string       // short names, glob patterns or numeric ranges (like "1-12") of the routes to keep
+	Area            Area           // when set, only trips stopping inside the area are kept
+	Clip            bool           // cut the kept trips down to their part inside Area
+	From            time.Time      // when set, trips only running before this date are dropped, and so are calendar dates before it
+	To              time.Time      // when set, trips only running after this date are dropped, and so are calendar dates after it
+}
+
+func (filter Filter) hasWindow() bool {
+	return !filter.From.IsZero() || !filter.To.IsZero()
+}
+
+// idMatcher matches IDs against a list of exact IDs, glob patterns and numeric ranges, and optionally a regular
+// expression. A matcher without any values matches everything.
+type idMatcher struct {
+	ids     util.StringSet
+	globs   []string
+	ranges  [][2]int
+	pattern *regexp.Regexp
+}
+
+var numericRange = regexp.MustCompile(`^(\d+)-(\d+)$`)
+
+func newIdMatcher(values []string, pattern *regexp.Regexp, allowRanges bool) (*idMatcher, error) {
+	matcher := &idMatcher{ids: util.StringSet{}, pattern: pattern}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if bounds := numericRange.FindStringSubmatch(value); allowRanges && bounds != nil {
+			from, _ := strconv.Atoi(bounds[1])
+			to, _ := strconv.Atoi(bounds[2])
+			matcher.ranges = append(matcher.ranges, [2]int{from, to})
+			continue
+		}
+
+		if !strings.ContainsAny(value, "*?[\\") {
+			matcher.ids.Add(value)
+			continue
+		}
+
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", value, err)
+		}
+		matcher.globs = append(matcher.globs, value)
+	}
+	return matcher, nil
+}
+
+func (matcher *idMatcher) empty() bool {
+	return len(matcher.ids) == 0 && len(matcher.globs) == 0 && len(matcher.ranges) == 0 && matcher.pattern == nil
+}
+
+func (matcher *idMatcher) match(id string) bool {
+	if matcher.empty() || matcher.ids.Has(id) {
+		return true
+	}
+	for _, glob := range matcher.globs {
+		if matched, _ := path.Match(glob, id); matched {
+			return true
+		}
+	}
+	if number, err := strconv.Atoi(id); err == nil {
+		for _, bounds := range matcher.ranges {
+			if number >= bounds[0] && number <= bounds[1] {
+				return true
+			}
+		}
+	}
+	return matcher.pattern != nil && matcher.pattern.MatchString(id)
+}
+
+// Extractor copies the part of a feed selected by a Filter, together with everything that part references. Every
+// table is scanned once and membership is checked against sets, so extracting from a national feed stays linear.
+//
+// stop_times are handed to the extractor one by one with AddStopTime, so they can be streamed from a Reader instead
+// of being loaded into the source store.
+type Extractor struct {
+	source *Store
+	filter Filter
+	result Store
+
+	routeIds       util.StringSet // routes matching the selectors of the filter
+	tripIds        util.StringSet // trips of those routes
+	areaStopIds    util.StringSet // stops inside the area of the filter, if it has one
+	servingTripIds util.StringSet // trips stopping inside the area of the filter, found by Scan
+
+	services          *ServiceCalendar
+	firstDay, lastDay int             // the date window of the filter, as day numbers
+	servicesInWindow  map[string]bool // whether a service runs inside the date window, by service ID
+}
+
+// NewExtractor selects the routes and trips matching filter from source
+func NewExtractor(source *Store, filter Filter) (*Extractor, error) {
+	e := &Extractor{
+		source:   source,
+		filter:   filter,
+		routeIds: util.StringSet{},
+		tripIds:  util.StringSet{},
+	}
+
+	agencies, err := newIdMatcher(filter.Agencies, filter.AgencyPattern, false)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := newIdMatcher(filter.Routes, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	routeTypes, err := newIdMatcher(filter.RouteTypes, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	shortNames, err := newIdMatcher(filter.RouteShortNames, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	if agencies.empty() && routes.empty() && routeTypes.empty() && shortNames.empty() && filter.Area == nil && !filter.hasWindow() {
+		return nil, ErrEmptyFilter
+	}
+
+	if filter.hasWindow() {
+		e.services, err = NewServiceCalendar(source)
+		if err != nil {
+			return nil, err
+		}
+
+		e.firstDay, e.lastDay = math.MinInt32, math.MaxInt32
+		if !filter.From.IsZero() {
+			e.firstDay = dayNumber(filter.From)
+		}
+		if !filter.To.IsZero() {
+			e.lastDay = dayNumber(filter.To)
+		}
+		if e.firstDay > e.lastDay {
+			return nil, fmt.Errorf("date window ends before it starts")
+		}
+		e.servicesInWindow = map[string]bool{}
+	}
+
+	for _, route := range source.Route {
+		if agencies.match(e.agencyOf(route)) && routes.match(route.RouteId) && routeTypes.match(strings.TrimSpace(route.RouteType)) && shortNames.match(strings.TrimSpace(route.RouteShortName)) {
+			e.routeIds.Add(route.RouteId)
+		}
+	}
+
+	for _, trip := range source.Trip {
+		if e.routeIds.Has(trip.RouteId) && e.runsInWindow(trip.ServiceId) {
+			e.tripIds.Add(trip.TripId)
+		}
+	}
+
+	if filter.Area != nil {
+		e.areaStopIds = util.StringSet{}
+		e.servingTripIds = util.StringSet{}
+		for _, stop := range source.Stop {
+			if filter.Area.Contains(stop.Lat, stop.Lon) {
+				e.areaStopIds.Add(stop.Id)
+			}
+		}
+	}
+
+	return e, nil
+}
+
+func (e *Extractor) agencyOf(route Route) string {
+	if route.AgencyId == "" && len(e.source.Agency) == 1 { // agency_id may be left out of routes.txt when a feed has a single agency
+		return e.source.Agency[0].Id
+	}
+	return route.AgencyId
+}
+
+// runsInWindow tells whether serviceId runs on any day of the date window of the filter
+func (e *Extractor) runsInWindow(serviceId string) bool {
+	if e.services == nil {
+		return true
+	}
+
+	runs, ok := e.servicesInWindow[serviceId]
+	if !ok {
+		for _, day := range e.services.serviceDays(serviceId) {
+			if day >= e.firstDay && day <= e.lastDay {
+				runs = true
+				break
+			}
+		}
+		e.servicesInWindow[serviceId] = runs
+	}
+	return runs
+}
+
+// inWindow tells whether date, a GTFS date, falls inside the date window of the filter
+func (e *Extractor) inWindow(date string) bool {
+	if e.services == nil {
+		return true
+	}
+
+	parsed, err := ParseDate(date)
+	if err != nil {
+		return false
+	}
+	day := dayNumber(parsed)
+	return day >= e.firstDay && day <= e.lastDay
+}
+
+// clampCalendar shortens the date range of calendar to the date window of the filter, ok being false when nothing of
+// it is left
+func (e *Extractor) clampCalendar(calendar Calendar) (clamped Calendar, ok bool) {
+	if e.services == nil {
+		return calendar, true
+	}
+
+	start, startErr := ParseDate(calendar.StartDate)
+	end, endErr := ParseDate(calendar.EndDate)
+	if startErr != nil || endErr != nil {
+		return calendar, false
+	}
+
+	if dayNumber(start) < e.firstDay {
+		calendar.StartDate = FormatDate(dayDate(e.firstDay))
+	}
+	if dayNumber(end) > e.lastDay {
+		calendar.EndDate = FormatDate(dayDate(e.lastDay))
+	}
+	return calendar, calendar.StartDate <= calendar.EndDate
+}
+
+// NeedsScan tells whether trips are selected by the stops they serve. In that case every stop_time has to be passed
+// to Scan before the first call to AddStopTime.
+func (e *Extractor) NeedsScan() bool {
+	return e.servingTripIds != nil
+}
+
+// Scan notes the trip of stopTime as serving the area of the filter when stopTime is at a stop inside it
+func (e *Extractor) Scan(stopTime StopTime) {
+	if e.areaStopIds.Has(stopTime.StopId) && e.tripIds.Has(stopTime.TripId) {
+		e.servingTripIds.Add(stopTime.TripId)
+	}
+}
+
+func (e *Extractor) keepsTrip(tripId string) bool {
+	return e.tripIds.Has(tripId) && (e.servingTripIds == nil || e.servingTripIds.Has(tripId))
+}
+
+// AddStopTime keeps stopTime when it belongs to one of the extracted trips
+func (e *Extractor) AddStopTime(stopTime StopTime) {
+	if e.keepsTrip(stopTime.TripId) {
+		e.result.StopTime = append(e.result.StopTime, stopTime)
+	}
+}
+
+// Result copies the extracted trips together with the routes, agencies, calendars, stops, shapes and transfers they
+// reference and returns the extracted feed
+func (e *Extractor) Result() Store {
+	var clipped map[string]clippedTrip
+	var trimmedShapes []Shape
+	if e.filter.Clip && e.filter.Area != nil {
+		clipped, trimmedShapes = e.clip()
+	}
+
+	tripIds := util.StringSet{}
+	routeIds := util.StringSet{}
+	serviceIds := util.StringSet{}
+	shapeIds := util.StringSet{}
+	for _, trip := range e.source.Trip {
+		if !e.keepsTrip(trip.TripId) {
+			continue
+		}
+		if clip, ok := clipped[trip.TripId]; ok {
+			if clip.dropped {
+				continue
+			}
+			trip.ShapeId = clip.shapeId
+		}
+
+		e.result.Trip = append(e.result.Trip, trip)
+		tripIds.Add(trip.TripId)
+		routeIds.Add(trip.RouteId)
+		serviceIds.Add(trip.ServiceId)
+		if trip.ShapeId != "" {
+			shapeIds.Add(trip.ShapeId)
+		}
+	}
+
+	agencyIds := util.StringSet{}
+	for _, route := range e.source.Route {
+		if routeIds.Has(route.RouteId) {
+			e.result.Route = append(e.result.Route, route)
+			agencyIds.Add(e.agencyOf(route))
+		}
+	}
+
+	for _, agency := range e.source.Agency {
+		if agencyIds.Has(agency.Id) {
+			e.result.Agency = append(e.result.Agency, agency)
+		}
+	}
+
+	for _, calendar := range e.source.Calendar {
+		if calendar, ok := e.clampCalendar(calendar); ok && serviceIds.Has(calendar.ServiceId) {
+			e.result.Calendar = append(e.result.Calendar, calendar)
+		}
+	}
+
+	for _, calendarDate := range e.source.CalendarDates {
+		if serviceIds.Has(calendarDate.ServiceId) && e.inWindow(calendarDate.Date) {
+			e.result.CalendarDates = append(e.result.CalendarDates, calendarDate)
+		}
+	}
+
+	stopIds := e.stopClosure()
+	for _, stop := range e.source.Stop {
+		if stopIds.Has(stop.Id) {
+			e.result.Stop = append(e.result.Stop, stop)
+		}
+	}
+
+	for _, transfer := range e.source.Transfer {
+		if stopIds.Has(transfer.FromStopId) && stopIds.Has(transfer.ToStopId) &&
+			(transfer.FromRouteId == "" || routeIds.Has(transfer.FromRouteId)) && (transfer.ToRouteId == "" || routeIds.Has(transfer.ToRouteId)) &&
+			(transfer.FromTripId == "" || tripIds.Has(transfer.FromTripId)) && (transfer.ToTripId == "" || tripIds.Has(transfer.ToTripId)) {
+			e.result.Transfer = append(e.result.Transfer, transfer)
+		}
+	}
+
+	for _, shape := range e.source.Shape {
+		if shapeIds.Has(shape.Id) {
+			e.result.Shape = append(e.result.Shape, shape)
+		}
+	}
+	e.result.Shape = append(e.result.Shape, trimmedShapes...)
+
+	return e.result
+}
+
+type clippedTrip struct {
+	dropped bool   // the trip stops inside the area less than twice
+	shapeId string // the shape of the trip after trimming it to the clipped stop_times
+}
+
+// trimmedShape is the part of a shape between two of its points
+type trimmedShape struct {
+	shapeId  string
+	from, to int
+}
+
+// clip cuts the stop_times of every extracted trip down to the part between its first and last stop inside the area,
+// renumbering them from 1. Trips stopping inside the area less than twice are dropped. Shapes are trimmed to the
+// clipped stop_times, returning the points of the trimmed shapes under new shape IDs.
+func (e *Extractor) clip() (map[string]clippedTrip, []Shape) {
+	tripOrder, tripStopTimes := stopTimesByTrip(e.result.StopTime)
+
+	shapeIdByTrip := map[string]string{}
+	for _, trip := range e.source.Trip {
+		if _, ok := tripStopTimes[trip.TripId]; ok {
+			shapeIdByTrip[trip.TripId] = trip.ShapeId
+		}
+	}
+
+	clipped := map[string]clippedTrip{}
+	clippedStopTimes := make([]StopTime, 0, len(e.result.StopTime))
+	trimmed := map[string]trimmedShape{}
+	trimmedOrder := make([]string, 0)
+	var shapePoints map[string][]Shape
+	var stops map[string]Stop
+
+	for _, tripId := range tripOrder {
+		stopTimes := tripStopTimes[tripId]
+
+		first, last := -1, -1
+		for i, stopTime := range stopTimes {
+			if e.areaStopIds.Has(stopTime.StopId) {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		if first < 0 || first == last {
+			clipped[tripId] = clippedTrip{dropped: true}
+			continue
+		}
+
+		clip := clippedTrip{shapeId: shapeIdByTrip[tripId]}
+		if (first > 0 || last < len(stopTimes)-1) && clip.shapeId != "" {
+			if shapePoints == nil {
+				_, shapePoints = shapesById(e.source.Shape)
+				stops = stopsById(e.source.Stop)
+			}
+
+			points := shapePoints[clip.shapeId]
+			from, to := trimShape(points, stopTimes[first], stopTimes[last], stops)
+			if from > 0 || to < len(points)-1 {
+				clip.shapeId = fmt.Sprintf("%s_%d_%d", clip.shapeId, points[from].PTSequence, points[to].PTSequence)
+				if _, ok := trimmed[clip.shapeId]; !ok {
+					trimmed[clip.shapeId] = trimmedShape{shapeId: shapeIdByTrip[tripId], from: from, to: to}
+					trimmedOrder = append(trimmedOrder, clip.shapeId)
+				}
+			}
+		}
+		clipped[tripId] = clip
+
+		for i, stopTime := range stopTimes[first : last+1] {
+			stopTime.Sequence = i + 1
+			clippedStopTimes = append(clippedStopTimes, stopTime)
+		}
+	}
+	e.result.StopTime = clippedStopTimes
+
+	trimmedShapes := make([]Shape, 0)
+	for _, shapeId := range trimmedOrder {
+		trim := trimmed[shapeId]
+		for _, point := range shapePoints[trim.shapeId][trim.from : trim.to+1] {
+			point.Id = shapeId
+			trimmedShapes = append(trimmedShapes, point)
+		}
+	}
+
+	return clipped, trimmedShapes
+}
+
+// trimShape finds the first and last point of the part of a shape between two stop_times of a trip, using the
+// distances traveled when the feed has them and the points closest to the stops otherwise
+func trimShape(points []Shape, first, last StopTime, stops map[string]Stop) (int, int) {
+	if len(points) == 0 {
+		return 0, -1
+	}
+
+	if last.ShapeDistTraveled > 0 && points[len(points)-1].DistTraveled > 0 {
+		from, to := 0, len(points)-1
+		for i, point := range points {
+			if point.DistTraveled <= first.ShapeDistTraveled {
+				from = i
+			}
+		}
+		for i := len(points) - 1; i >= from; i-- {
+			if points[i].DistTraveled >= last.ShapeDistTraveled {
+				to = i
+			}
+		}
+		return from, to
+	}
+
+	from := closestPoint(points, 0, stops[first.StopId])
+	return from, closestPoint(points, from, stops[last.StopId])
+}
+
+func closestPoint(points []Shape, start int, stop Stop) int {
+	closest, closestDistance := start, math.Inf(1)
+	for i := start; i < len(points); i++ {
+		if d := distance(points[i].Lat, points[i].Lon, stop.Lat, stop.Lon); d < closestDistance {
+			closest, closestDistance = i, d
+		}
+	}
+	return closest
+}
+
+// stopClosure returns the stops served by the extracted stop_times, together with their parent stations and the
+// entrances, generic nodes and boarding areas belonging to those, so the structure of stations is kept intact
+func (e *Extractor) stopClosure() util.StringSet {
+	stopIds := util.StringSet{}
+	for _, stopTime := range e.result.StopTime {
+		stopIds.Add(stopTime.StopId)
+	}
+
+	stops := stopsById(e.source.Stop)
+	for stopId := range stopIds {
+		for parent := stops[stopId].ParentStation; parent != "" && !stopIds.Has(parent); parent = stops[parent].ParentStation {
+			stopIds.Add(parent)
+		}
+	}
+
+	for _, stop := range e.source.Stop {
+		if stop.LocationType >= 2 && stopIds.Has(stop.ParentStation) {
+			stopIds.Add(stop.Id)
+		}
+	}
+
+	return stopIds
+}
+
+// Extract returns the part of store selected by filter, including its stop_times
+func (store *Store) Extract(filter Filter) (Store, error) {
+	e, err := NewExtractor(store, filter)
+	if err != nil {
+		return Store{}, err
+	}
+
+	if e.NeedsScan() {
+		for _, stopTime := range store.StopTime {
+			e.Scan(stopTime)
+		}
+	}
+	for _, stopTime := range store.StopTime {
+		e.AddStopTime(stopTime)
+	}
+	return e.Result(), nil
+}
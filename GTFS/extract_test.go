@@ -0,0 +1,333 @@
+package GTFS
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	source := testStore()
+	source.Stop = append(source.Stop,
+		Stop{Id: "station", Name: "Centraal", LocationType: 1, ParentStation: "area"},
+		Stop{Id: "area", Name: "Centrum", LocationType: 1},
+		Stop{Id: "entrance", Name: "Centraal Noord", LocationType: 2, ParentStation: "station"},
+		Stop{Id: "elsewhere", Name: "Elsewhere", LocationType: 1},
+	)
+	result, err := source.Extract(Filter{Agencies: []string{"TB"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string][2]int{
+		"agency":         {len(result.Agency), 1},
+		"calendar":       {len(result.Calendar), 1},
+		"calendar_dates": {len(result.CalendarDates), 1},
+		"routes":         {len(result.Route), 1},
+		"shapes":         {len(result.Shape), 2},
+		"stop_times":     {len(result.StopTime), 2},
+		"stops":          {len(result.Stop), 5},
+		"transfers":      {len(result.Transfer), 1},
+		"trips":          {len(result.Trip), 1},
+	}
+	for fileType, count := range counts {
+		if count[0] != count[1] {
+			t.Errorf("%s: extracted %d rows, expected %d", fileType, count[0], count[1])
+		}
+	}
+	if result.Trip[0].TripId != "t1" || result.Route[0].RouteId != "r1" {
+		t.Errorf("extracted the wrong trip or route: %+v %+v", result.Trip, result.Route)
+	}
+}
+
+func TestExtractSelectors(t *testing.T) {
+	source := testStore()
+	tests := []struct {
+		filter Filter
+		routes string
+	}{
+		{Filter{Agencies: []string{"T*"}}, "r1,r2"},
+		{Filter{AgencyPattern: regexp.MustCompile("^TT$")}, "r2"},
+		{Filter{Routes: []string{"r2"}}, "r2"},
+		{Filter{RouteTypes: []string{"3"}}, "r1"},
+		{Filter{RouteShortNames: []string{"1-12"}}, "r1,r2"},
+		{Filter{Agencies: []string{"TB", "TT"}, RouteTypes: []string{"0"}}, "r2"},
+	}
+
+	for _, test := range tests {
+		result, err := source.Extract(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		routes := make([]string, 0)
+		for _, route := range result.Route {
+			routes = append(routes, route.RouteId)
+		}
+		if strings.Join(routes, ",") != test.routes {
+			t.Errorf("%+v: extracted routes %v, expected %s", test.filter, routes, test.routes)
+		}
+	}
+
+	if _, err := source.Extract(Filter{}); err != ErrEmptyFilter {
+		t.Errorf("expected an empty filter to fail, got %v", err)
+	}
+}
+
+func TestExtractAreaClip(t *testing.T) {
+	source := Store{
+		Agency: []Agency{{Id: "A"}},
+		Route:  []Route{{RouteId: "r1"}},
+		Trip:   []Trip{{RouteId: "r1", ServiceId: "s", TripId: "through", ShapeId: "sh"}, {RouteId: "r1", ServiceId: "s", TripId: "leaving", ShapeId: "sh"}},
+	}
+	for i, stopId := range []string{"a", "b", "c", "d"} {
+		lat := 52 + float64(i)/10
+		source.Stop = append(source.Stop, Stop{Id: stopId, Lat: lat, Lon: 5})
+		source.StopTime = append(source.StopTime, StopTime{TripId: "through", Sequence: i + 1, StopId: stopId, ShapeDistTraveled: float64(i * 1000)})
+		if i < 2 {
+			source.StopTime = append(source.StopTime, StopTime{TripId: "leaving", Sequence: i + 1, StopId: stopId})
+		}
+	}
+	for i := 0; i <= 6; i++ {
+		source.Shape = append(source.Shape, Shape{Id: "sh", PTSequence: i, Lat: 52 + float64(i)/20, Lon: 5, DistTraveled: float64(i * 500)})
+	}
+
+	result, err := source.Extract(Filter{Area: BoundingBox{MinLon: 4.9, MinLat: 52.05, MaxLon: 5.1, MaxLat: 52.25}, Clip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Trip) != 1 || result.Trip[0].TripId != "through" || result.Trip[0].ShapeId != "sh_2_4" {
+		t.Fatalf("expected only the through trip on a trimmed shape, got %+v", result.Trip)
+	}
+	if len(result.StopTime) != 2 || result.StopTime[0].StopId != "b" || result.StopTime[0].Sequence != 1 || result.StopTime[1].StopId != "c" || result.StopTime[1].Sequence != 2 {
+		t.Errorf("expected stop_times b and c renumbered from 1, got %+v", result.StopTime)
+	}
+	if len(result.Shape) != 3 || result.Shape[0].DistTraveled != 1000 || result.Shape[2].DistTraveled != 2000 {
+		t.Errorf("expected the shape between b and c, got %+v", result.Shape)
+	}
+	if len(result.Stop) != 2 {
+		t.Errorf("expected stops b and c, got %+v", result.Stop)
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	square := Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}}, // hole
+	}
+	if !square.Contains(2, 2) || square.Contains(5, 5) || square.Contains(11, 5) {
+		t.Error("point in polygon test failed")
+	}
+}
+
+func TestExtractDateWindow(t *testing.T) {
+	source := Store{
+		Agency: []Agency{{Id: "A"}},
+		Route:  []Route{{RouteId: "r1", AgencyId: "A"}},
+		Trip: []Trip{
+			{RouteId: "r1", ServiceId: "october", TripId: "october"},
+			{RouteId: "r1", ServiceId: "december", TripId: "december"},
+			{RouteId: "r1", ServiceId: "extra", TripId: "extra"},
+		},
+		Calendar: []Calendar{
+			{ServiceId: "october", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, Saturday: 1, Sunday: 1, StartDate: "20261001", EndDate: "20261031"},
+			{ServiceId: "december", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, Saturday: 1, Sunday: 1, StartDate: "20261201", EndDate: "20261231"},
+		},
+		CalendarDates: []CalendarDate{
+			{ServiceId: "october", Date: "20261005", ExceptionType: ServiceRemoved},
+			{ServiceId: "october", Date: "20261012", ExceptionType: ServiceRemoved},
+			{ServiceId: "extra", Date: "20261015", ExceptionType: ServiceAdded},
+			{ServiceId: "extra", Date: "20261120", ExceptionType: ServiceAdded},
+			{ServiceId: "december", Date: "20261015", ExceptionType: ServiceRemoved},
+		},
+	}
+	for _, tripId := range []string{"october", "december", "extra"} {
+		source.StopTime = append(source.StopTime, StopTime{TripId: tripId, Sequence: 1, StopId: "a"}, StopTime{TripId: tripId, Sequence: 2, StopId: "b"})
+	}
+	source.Stop = []Stop{{Id: "a"}, {Id: "b"}}
+
+	date := func(value string) time.Time {
+		d, err := ParseDate(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	result, err := source.Extract(Filter{Agencies: []string{"A"}, From: date("20261010"), To: date("20261020")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the december service never runs in the window, even though one of its calendar dates falls inside it
+	tripIds := make([]string, 0)
+	for _, trip := range result.Trip {
+		tripIds = append(tripIds, trip.TripId)
+	}
+	if strings.Join(tripIds, ",") != "october,extra" {
+		t.Errorf("extracted trips %v, expected october and extra", tripIds)
+	}
+	if len(result.StopTime) != 4 {
+		t.Errorf("extracted %d stop_times, expected 4", len(result.StopTime))
+	}
+
+	if len(result.Calendar) != 1 || result.Calendar[0].ServiceId != "october" || result.Calendar[0].StartDate != "20261010" || result.Calendar[0].EndDate != "20261020" {
+		t.Errorf("expected the october calendar clamped to the window, got %+v", result.Calendar)
+	}
+	expected := []CalendarDate{
+		{ServiceId: "october", Date: "20261012", ExceptionType: ServiceRemoved},
+		{ServiceId: "extra", Date: "20261015", ExceptionType: ServiceAdded},
+	}
+	if !reflect.DeepEqual(result.CalendarDates, expected) {
+		t.Errorf("got calendar dates %+v, expected %+v", result.CalendarDates, expected)
+	}
+
+	// an open-ended window only clamps one side
+	result, err = source.Extract(Filter{Agencies: []string{"A"}, From: date("20261210")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Calendar) != 1 || result.Calendar[0].StartDate != "20261210" || result.Calendar[0].EndDate != "20261231" {
+		t.Errorf("expected the december calendar from the 10th, got %+v", result.Calendar)
+	}
+
+	if _, err := source.Extract(Filter{Agencies: []string{"A"}, From: date("20261020"), To: date("20261010")}); err == nil {
+		t.Error("expected a reversed window to fail")
+	}
+}
+
+func TestReadGeoJSON(t *testing.T) {
+	square := `[[[4.9, 52.3], [5.0, 52.3], [5.0, 52.4], [4.9, 52.4], [4.9, 52.3]]]`
+	tests := []struct {
+		document string
+		polygons int
+	}{
+		{`{"type": "Polygon", "coordinates": ` + square + `}`, 1},
+		{`{"type": "MultiPolygon", "coordinates": [` + square + `, ` + square + `]}`, 2},
+		{`{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": ` + square + `}}`, 1},
+		{`{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [4.95, 52.35]}},
+			{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [` + square + `]}},
+			{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": ` + square + `}}
+		]}`, 2},
+	}
+	for _, test := range tests {
+		polygons, err := ReadGeoJSON(strings.NewReader(test.document))
+		if err != nil {
+			t.Errorf("%s: %v", test.document, err)
+			continue
+		}
+		if len(polygons) != test.polygons || !polygons.Contains(52.35, 4.95) || polygons.Contains(52.45, 4.95) {
+			t.Errorf("%s: read %d polygons %v, expected %d around 52.35,4.95", test.document, len(polygons), polygons, test.polygons)
+		}
+	}
+
+	for _, document := range []string{
+		`{"type": "Point", "coordinates": [4.95, 52.35]}`,
+		`{"type": "Circle"}`,
+		`{"type": "Polygon", "coordinates": "square"}`,
+		`not json`,
+	} {
+		if _, err := ReadGeoJSON(strings.NewReader(document)); err == nil {
+			t.Errorf("%s: expected an error", document)
+		}
+	}
+}
+
+// TestExtractPolygonFile follows the extract --polygon flag: read a GeoJSON file and extract the trips stopping inside it
+func TestExtractPolygonFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "area.geojson", `{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [
+		[[[4.9, 52.05], [5.1, 52.05], [5.1, 52.25], [4.9, 52.25], [4.9, 52.05]]]
+	]}}`)
+	file, err := os.Open(filepath.Join(dir, "area.geojson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	polygons, err := ReadGeoJSON(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := Store{
+		Agency: []Agency{{Id: "A"}},
+		Route:  []Route{{RouteId: "r1"}},
+		Trip:   []Trip{{RouteId: "r1", ServiceId: "s", TripId: "inside"}, {RouteId: "r1", ServiceId: "s", TripId: "outside"}},
+	}
+	for i, stopId := range []string{"a", "b", "c", "d"} {
+		source.Stop = append(source.Stop, Stop{Id: stopId, Lat: 52 + float64(i)/10, Lon: 5})
+	}
+	source.StopTime = []StopTime{
+		{TripId: "inside", Sequence: 1, StopId: "a"}, {TripId: "inside", Sequence: 2, StopId: "b"},
+		{TripId: "outside", Sequence: 1, StopId: "a"}, {TripId: "outside", Sequence: 2, StopId: "d"},
+	}
+
+	result, err := source.Extract(Filter{Area: polygons})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Trip) != 1 || result.Trip[0].TripId != "inside" {
+		t.Errorf("expected only the trip stopping inside the polygon, got %+v", result.Trip)
+	}
+}
+
+// syntheticStore builds a feed shaped like a national feed: many agencies, each with their own routes, trips, stops
+// and shapes
+func syntheticStore(agencies, routesPerAgency, tripsPerRoute, stopsPerTrip int) Store {
+	store := Store{}
+	for a := 0; a < agencies; a++ {
+		agencyId := fmt.Sprintf("A%d", a)
+		store.Agency = append(store.Agency, Agency{Id: agencyId, Name: agencyId})
+
+		for r := 0; r < routesPerAgency; r++ {
+			routeId := fmt.Sprintf("%s-R%d", agencyId, r)
+			store.Route = append(store.Route, Route{RouteId: routeId, AgencyId: agencyId, RouteType: "3"})
+
+			shapeId := routeId + "-S"
+			for s := 0; s < stopsPerTrip; s++ {
+				stopId := fmt.Sprintf("%s-%d", routeId, s)
+				store.Stop = append(store.Stop, Stop{Id: stopId, Name: stopId, Lat: 52 + float64(s)/1000, Lon: 5})
+				store.Shape = append(store.Shape, Shape{Id: shapeId, PTSequence: s, Lat: 52 + float64(s)/1000, Lon: 5})
+			}
+
+			for i := 0; i < tripsPerRoute; i++ {
+				tripId := fmt.Sprintf("%s-T%d", routeId, i)
+				serviceId := fmt.Sprintf("%s-D%d", agencyId, i%7)
+				store.Trip = append(store.Trip, Trip{RouteId: routeId, ServiceId: serviceId, TripId: tripId, ShapeId: shapeId})
+
+				for s := 0; s < stopsPerTrip; s++ {
+					departure := NewTime(6, 0, 0) + Time(i*600+s*120)
+					store.StopTime = append(store.StopTime, StopTime{TripId: tripId, Sequence: s, StopId: fmt.Sprintf("%s-%d", routeId, s), ArrivalTime: departure, DepartureTime: departure})
+				}
+			}
+		}
+
+		for d := 0; d < 7; d++ {
+			store.CalendarDates = append(store.CalendarDates, CalendarDate{ServiceId: fmt.Sprintf("%s-D%d", agencyId, d), Date: fmt.Sprintf("202610%02d", 19+d), ExceptionType: ServiceAdded})
+		}
+	}
+	return store
+}
+
+func BenchmarkExtract(b *testing.B) {
+	for _, agencies := range []int{10, 100} {
+		store := syntheticStore(agencies, 10, 40, 25)
+		b.Run(fmt.Sprintf("%d stop_times", len(store.StopTime)), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result, err := store.Extract(Filter{Agencies: []string{"A1"}})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(result.StopTime) != 10*40*25 {
+					b.Fatalf("extracted %d stop_times", len(result.StopTime))
+				}
+			}
+		})
+	}
+}
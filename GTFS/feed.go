@@ -0,0 +1,218 @@
+package GTFS
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Feed indexes a store for lookups by ID and between related tables. The indexes are built once by NewFeed, so the
+// store shouldn't be changed afterwards. Slices returned by its methods are shared and shouldn't be modified.
+type Feed struct {
+	Store *Store
+
+	agencies map[string]int
+	routes   map[string]int
+	trips    map[string]int
+	stops    map[string]int
+
+	stopTimes       []StopTime        // copy of the stop times, grouped by trip and ordered by sequence
+	tripStopTimes   map[string][2]int // range of the stop times of each trip
+	stopStopTimes   map[string][]*StopTime
+	routeTrips      map[string][]*Trip
+	serviceTrips    map[string][]*Trip
+	shapePoints     map[string][]Shape
+	stopChildren    map[string][]*Stop
+	serviceCalendar *ServiceCalendar
+}
+
+func NewFeed(store *Store) *Feed {
+	feed := &Feed{
+		Store:         store,
+		agencies:      make(map[string]int, len(store.Agency)),
+		routes:        make(map[string]int, len(store.Route)),
+		trips:         make(map[string]int, len(store.Trip)),
+		stops:         make(map[string]int, len(store.Stop)),
+		tripStopTimes: make(map[string][2]int, len(store.Trip)),
+		stopStopTimes: make(map[string][]*StopTime, len(store.Stop)),
+		routeTrips:    make(map[string][]*Trip, len(store.Route)),
+		serviceTrips:  map[string][]*Trip{},
+		stopChildren:  map[string][]*Stop{},
+	}
+
+	for i, agency := range store.Agency {
+		feed.agencies[agency.Id] = i
+	}
+	for i, route := range store.Route {
+		feed.routes[route.RouteId] = i
+	}
+	for i := range store.Trip {
+		trip := &store.Trip[i]
+		feed.trips[trip.TripId] = i
+		feed.routeTrips[trip.RouteId] = append(feed.routeTrips[trip.RouteId], trip)
+		feed.serviceTrips[trip.ServiceId] = append(feed.serviceTrips[trip.ServiceId], trip)
+	}
+	for i := range store.Stop {
+		stop := &store.Stop[i]
+		feed.stops[stop.Id] = i
+		if stop.ParentStation != "" {
+			feed.stopChildren[stop.ParentStation] = append(feed.stopChildren[stop.ParentStation], stop)
+		}
+	}
+
+	feed.indexStopTimes()
+	_, feed.shapePoints = shapesById(store.Shape)
+	return feed
+}
+
+// indexStopTimes copies the stop times grouped by trip and ordered by sequence, so the stop times of a trip are a range
+// of the copy
+func (feed *Feed) indexStopTimes() {
+	source := feed.Store.StopTime
+
+	// count the stop times of each trip, in order of first appearance, to find where each trip starts in the copy
+	tripIndex := make(map[string]int, len(feed.Store.Trip))
+	tripIds := make([]string, 0, len(feed.Store.Trip))
+	counts := make([]int, 0, len(feed.Store.Trip))
+	for _, stopTime := range source {
+		i, ok := tripIndex[stopTime.TripId]
+		if !ok {
+			i = len(tripIds)
+			tripIndex[stopTime.TripId] = i
+			tripIds = append(tripIds, stopTime.TripId)
+			counts = append(counts, 0)
+		}
+		counts[i]++
+	}
+
+	next := make([]int, len(counts))
+	start := 0
+	for i, tripId := range tripIds {
+		feed.tripStopTimes[tripId] = [2]int{start, start + counts[i]}
+		next[i] = start
+		start += counts[i]
+	}
+
+	feed.stopTimes = make([]StopTime, len(source))
+	for _, stopTime := range source {
+		i := tripIndex[stopTime.TripId]
+		feed.stopTimes[next[i]] = stopTime
+		next[i]++
+	}
+
+	for _, bounds := range feed.tripStopTimes {
+		trip := feed.stopTimes[bounds[0]:bounds[1]]
+		if !sort.SliceIsSorted(trip, func(i, j int) bool { return trip[i].Sequence < trip[j].Sequence }) {
+			sort.SliceStable(trip, func(i, j int) bool { return trip[i].Sequence < trip[j].Sequence })
+		}
+	}
+
+	for i := range feed.stopTimes {
+		stopTime := &feed.stopTimes[i]
+		feed.stopStopTimes[stopTime.StopId] = append(feed.stopStopTimes[stopTime.StopId], stopTime)
+	}
+}
+
+// Agency returns the agency with the given ID, or nil. In a feed with a single agency, routes may leave agency_id
+// empty, so the empty ID finds that agency.
+func (feed *Feed) Agency(id string) *Agency {
+	if i, ok := feed.agencies[id]; ok {
+		return &feed.Store.Agency[i]
+	}
+	if id == "" && len(feed.Store.Agency) == 1 {
+		return &feed.Store.Agency[0]
+	}
+	return nil
+}
+
+// Route returns the route with the given ID, or nil
+func (feed *Feed) Route(id string) *Route {
+	if i, ok := feed.routes[id]; ok {
+		return &feed.Store.Route[i]
+	}
+	return nil
+}
+
+// Trip returns the trip with the given ID, or nil
+func (feed *Feed) Trip(id string) *Trip {
+	if i, ok := feed.trips[id]; ok {
+		return &feed.Store.Trip[i]
+	}
+	return nil
+}
+
+// Stop returns the stop with the given ID, or nil
+func (feed *Feed) Stop(id string) *Stop {
+	if i, ok := feed.stops[id]; ok {
+		return &feed.Store.Stop[i]
+	}
+	return nil
+}
+
+// TripStopTimes returns the stop times of a trip, ordered by stop_sequence
+func (feed *Feed) TripStopTimes(tripId string) []StopTime {
+	bounds, ok := feed.tripStopTimes[tripId]
+	if !ok {
+		return nil
+	}
+	return feed.stopTimes[bounds[0]:bounds[1]:bounds[1]]
+}
+
+// StopStopTimes returns the stop times at a stop, grouped by trip
+func (feed *Feed) StopStopTimes(stopId string) []*StopTime {
+	return feed.stopStopTimes[stopId]
+}
+
+// RouteTrips returns the trips of a route, in the order of trips.txt
+func (feed *Feed) RouteTrips(routeId string) []*Trip {
+	return feed.routeTrips[routeId]
+}
+
+// ServiceTrips returns the trips running on a service, in the order of trips.txt
+func (feed *Feed) ServiceTrips(serviceId string) []*Trip {
+	return feed.serviceTrips[serviceId]
+}
+
+// ShapePoints returns the points of a shape, ordered by shape_pt_sequence
+func (feed *Feed) ShapePoints(shapeId string) []Shape {
+	return feed.shapePoints[shapeId]
+}
+
+// Children returns the stops that have the given stop as parent_station, such as the platforms of a station
+func (feed *Feed) Children(stopId string) []*Stop {
+	return feed.stopChildren[stopId]
+}
+
+// ServiceCalendar returns the service calendar of the feed, built on first use
+func (feed *Feed) ServiceCalendar() (*ServiceCalendar, error) {
+	if feed.serviceCalendar == nil {
+		calendar, err := NewServiceCalendar(feed.Store)
+		if err != nil {
+			return nil, err
+		}
+		feed.serviceCalendar = calendar
+	}
+	return feed.serviceCalendar, nil
+}
+
+// tripLocation returns the timezone of the agency running a trip, or fallback when the agency has none. Loaded
+// timezones are cached in locations.
+func (feed *Feed) tripLocation(trip *Trip, fallback *time.Location, locations map[string]*time.Location) (*time.Location, error) {
+	route := feed.Route(trip.RouteId)
+	if route == nil {
+		return fallback, nil
+	}
+	agency := feed.Agency(route.AgencyId)
+	if agency == nil || agency.Timezone == "" {
+		return fallback, nil
+	}
+	if location, ok := locations[agency.Timezone]; ok {
+		return location, nil
+	}
+	location, err := time.LoadLocation(agency.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("agency %s: %w", agency.Id, err)
+	}
+	locations[agency.Timezone] = location
+	return location, nil
+}
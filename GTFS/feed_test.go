@@ -0,0 +1,51 @@
+package GTFS
+
+import "testing"
+
+func TestFeed(t *testing.T) {
+	store := testStore()
+	store.StopTime = append(store.StopTime,
+		StopTime{TripId: "t2", Sequence: 5, StopId: "b"},
+		StopTime{TripId: "t2", Sequence: 2, StopId: "a"},
+	)
+	store.Stop = append(store.Stop, Stop{Id: "station", LocationType: 1})
+	feed := NewFeed(&store)
+
+	if feed.Route("r2") == nil || feed.Route("r2").AgencyId != "TT" || feed.Route("missing") != nil {
+		t.Error("route lookup failed")
+	}
+	if feed.Agency("TB") == nil || feed.Trip("t1") == nil || feed.Stop("b") == nil {
+		t.Error("lookup by ID failed")
+	}
+
+	stopTimes := feed.TripStopTimes("t2")
+	if len(stopTimes) != 2 || stopTimes[0].Sequence != 2 || stopTimes[1].Sequence != 5 {
+		t.Errorf("expected the stop times of t2 ordered by sequence, got %+v", stopTimes)
+	}
+	if len(feed.TripStopTimes("t1")) != 2 || feed.TripStopTimes("missing") != nil {
+		t.Error("stop times of trip lookup failed")
+	}
+	if len(feed.StopStopTimes("b")) != 2 {
+		t.Errorf("expected two stop times at b, got %d", len(feed.StopStopTimes("b")))
+	}
+	if trips := feed.RouteTrips("r1"); len(trips) != 1 || trips[0].TripId != "t1" {
+		t.Errorf("unexpected trips of r1 %+v", trips)
+	}
+	if trips := feed.ServiceTrips("s,2"); len(trips) != 1 || trips[0].TripId != "t2" {
+		t.Errorf("unexpected trips of s,2 %+v", trips)
+	}
+	if points := feed.ShapePoints("sh1"); len(points) != 2 || points[0].PTSequence != 1 {
+		t.Errorf("unexpected points of sh1 %+v", points)
+	}
+	if children := feed.Children("station"); len(children) != 1 || children[0].Id != "a" {
+		t.Errorf("unexpected children of station %+v", children)
+	}
+}
+
+func BenchmarkNewFeed(b *testing.B) {
+	store := syntheticStore(100, 10, 40, 25)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewFeed(&store)
+	}
+}
@@ -0,0 +1,138 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Area is a geographic region that stops can be tested against
+type Area interface {
+	Contains(lat, lon float64) bool
+}
+
+type BoundingBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// ParseBoundingBox reads a bounding box written as minLon,minLat,maxLon,maxLat
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, fmt.Errorf("bounding box %q should be minLon,minLat,maxLon,maxLat", value)
+	}
+
+	values := [4]float64{}
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BoundingBox{}, fmt.Errorf("bounding box %q: %q is not a number", value, part)
+		}
+		values[i] = number
+	}
+
+	box := BoundingBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if box.MinLon > box.MaxLon || box.MinLat > box.MaxLat {
+		return BoundingBox{}, fmt.Errorf("bounding box %q: minimum is larger than maximum", value)
+	}
+	return box, nil
+}
+
+func (box BoundingBox) Contains(lat, lon float64) bool {
+	return lat >= box.MinLat && lat <= box.MaxLat && lon >= box.MinLon && lon <= box.MaxLon
+}
+
+// Polygon is an outer ring followed by the rings of its holes, each ring being a list of [lon, lat] points as in GeoJSON
+type Polygon [][][2]float64
+
+func (polygon Polygon) Contains(lat, lon float64) bool {
+	inside := false
+	for _, ring := range polygon { // even-odd rule over all rings, so points in a hole count as outside
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > lat) != (b[1] > lat) && lon < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+type MultiPolygon []Polygon
+
+func (polygons MultiPolygon) Contains(lat, lon float64) bool {
+	for _, polygon := range polygons {
+		if polygon.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []geoJSON       `json:"geometries"`
+	Features    []geoJSON       `json:"features"`
+}
+
+// ReadGeoJSON reads the polygons of a GeoJSON document: a Polygon or MultiPolygon geometry, a Feature holding one, or
+// a FeatureCollection or GeometryCollection of those
+func ReadGeoJSON(r io.Reader) (MultiPolygon, error) {
+	document := geoJSON{}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	polygons, err := document.polygons()
+	if err != nil {
+		return nil, err
+	}
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("GeoJSON document contains no polygons")
+	}
+	return polygons, nil
+}
+
+func (object geoJSON) polygons() (MultiPolygon, error) {
+	switch object.Type {
+	case "Polygon":
+		polygon := Polygon{}
+		err := json.Unmarshal(object.Coordinates, &polygon)
+		return MultiPolygon{polygon}, err
+	case "MultiPolygon":
+		polygons := MultiPolygon{}
+		err := json.Unmarshal(object.Coordinates, &polygons)
+		return polygons, err
+	case "Feature":
+		if object.Geometry == nil {
+			return nil, nil
+		}
+		return object.Geometry.polygons()
+	case "FeatureCollection", "GeometryCollection":
+		polygons := MultiPolygon{}
+		for _, member := range append(object.Features, object.Geometries...) {
+			memberPolygons, err := member.polygons()
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, memberPolygons...)
+		}
+		return polygons, nil
+	case "Point", "MultiPoint", "LineString", "MultiLineString":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported GeoJSON type %q", object.Type)
+}
+
+// distance returns an approximation of the distance in meters between two points, good enough to compare distances
+// between nearby points
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	x := (lon2 - lon1) * math.Cos((lat1+lat2)/2*math.Pi/180)
+	y := lat2 - lat1
+	return math.Sqrt(x*x+y*y) * 111195
+}
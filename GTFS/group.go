@@ -0,0 +1,50 @@
+package GTFS
+
+import (
+	"sort"
+)
+
+// shapesById groups shape points by shape, ordered by sequence, keeping the shapes in order of appearance
+func shapesById(points []Shape) ([]string, map[string][]Shape) {
+	ids := make([]string, 0)
+	shapes := map[string][]Shape{}
+	for _, point := range points {
+		if _, ok := shapes[point.Id]; !ok {
+			ids = append(ids, point.Id)
+		}
+		shapes[point.Id] = append(shapes[point.Id], point)
+	}
+	for _, shape := range shapes {
+		sort.SliceStable(shape, func(i, j int) bool {
+			return shape[i].PTSequence < shape[j].PTSequence
+		})
+	}
+	return ids, shapes
+}
+
+// stopTimesByTrip groups stop times by trip, ordered by sequence, keeping the trips in order of appearance
+func stopTimesByTrip(stopTimes []StopTime) ([]string, map[string][]StopTime) {
+	ids := make([]string, 0)
+	trips := map[string][]StopTime{}
+	for _, stopTime := range stopTimes {
+		if _, ok := trips[stopTime.TripId]; !ok {
+			ids = append(ids, stopTime.TripId)
+		}
+		trips[stopTime.TripId] = append(trips[stopTime.TripId], stopTime)
+	}
+	for _, trip := range trips {
+		sort.SliceStable(trip, func(i, j int) bool {
+			return trip[i].Sequence < trip[j].Sequence
+		})
+	}
+	return ids, trips
+}
+
+// stopsById indexes stops by their ID
+func stopsById(stops []Stop) map[string]Stop {
+	byId := make(map[string]Stop, len(stops))
+	for _, stop := range stops {
+		byId[stop.Id] = stop
+	}
+	return byId
+}
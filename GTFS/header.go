@@ -0,0 +1,52 @@
+package GTFS
+
+import (
+	"strings"
+)
+
+// columns that must be present in the header of each file type, as defined by the GTFS reference
+var requiredColumns = map[string][]string{
+	"agency":         {"agency_name", "agency_url", "agency_timezone"},
+	"calendar":       {"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"},
+	"calendar_dates": {"service_id", "date", "exception_type"},
+	"routes":         {"route_id", "route_type"},
+	"shapes":         {"shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence"},
+	"stop_times":     {"trip_id", "stop_id", "stop_sequence"},
+	"stops":          {"stop_id"},
+	"transfers":      {"from_stop_id", "to_stop_id", "transfer_type"},
+	"trips":          {"route_id", "service_id", "trip_id"},
+}
+
+// header maps the column names of a file to their position, as found in the header row of that file
+type header map[string]int
+
+func readHeader(filePath, fileType string, record []string) (header, error) {
+	columns := header{}
+	for i, column := range record {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\uFEFF") // some feeds are written with a UTF-8 BOM
+		}
+		columns[strings.TrimSpace(column)] = i
+	}
+
+	missing := make([]string, 0)
+	for _, column := range requiredColumns[fileType] {
+		if _, ok := columns[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &ParseError{File: filePath, Line: 1, Column: strings.Join(missing, ", "), Err: ErrMissingColumn}
+	}
+
+	return columns, nil
+}
+
+// get returns the value of column in line, or an empty string when the file does not have that column
+func (columns header) get(line []string, column string) string {
+	i, ok := columns[column]
+	if !ok || i >= len(line) {
+		return ""
+	}
+	return line[i]
+}
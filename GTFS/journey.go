@@ -0,0 +1,587 @@
+package GTFS
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kinds of Leg
+const (
+	LegTransit = "transit"
+	LegWalk    = "walk"
+)
+
+// walking speed in meters per second, used for transfers between stops that don't give a min_transfer_time
+const walkingSpeed = 1.2
+
+// transfer_type of a transfer that isn't possible
+const transferNotPossible = 3
+
+// never is the arrival time at a stop that isn't reached, and the time of a stop a trip has no time for
+const never = math.MaxInt64
+
+type Leg struct {
+	Mode           string    `json:"mode"`
+	FromStopId     string    `json:"from_stop_id"`
+	ToStopId       string    `json:"to_stop_id"`
+	Departure      time.Time `json:"departure"`
+	Arrival        time.Time `json:"arrival"`
+	TripId         string    `json:"trip_id,omitempty"`
+	RouteId        string    `json:"route_id,omitempty"`
+	RouteShortName string    `json:"route_short_name,omitempty"`
+	Headsign       string    `json:"headsign,omitempty"`
+}
+
+type Journey struct {
+	Departure time.Time `json:"departure"`
+	Arrival   time.Time `json:"arrival"`
+	Transfers int       `json:"transfers"`
+	Legs      []Leg     `json:"legs"`
+}
+
+type JourneyOptions struct {
+	MaxTransfers int
+	ChangeTime   time.Duration // time needed to change trips at a stop, or between platforms of a station, that transfers.txt doesn't give a time for
+}
+
+// Journeys plans journeys from one stop to another, leaving at or after depart, with the RAPTOR algorithm. It returns
+// the Pareto-optimal journeys: each arrives earlier than every journey with fewer transfers. Stations include their
+// platforms. Transfers between stops come from transfers.txt rows that don't name a route or trip; without a
+// min_transfer_time their walking time is estimated from the distance between the stops. Platforms of the same station
+// that transfers.txt doesn't connect take ChangeTime to change between.
+func (feed *Feed) Journeys(fromStopId, toStopId string, depart time.Time, options JourneyOptions) ([]Journey, error) {
+	from := feed.Stop(fromStopId)
+	if from == nil {
+		return nil, fmt.Errorf("%w %s", ErrUnknownStop, fromStopId)
+	}
+	to := feed.Stop(toStopId)
+	if to == nil {
+		return nil, fmt.Errorf("%w %s", ErrUnknownStop, toStopId)
+	}
+
+	p, err := newPlanner(feed, depart, options)
+	if err != nil {
+		return nil, err
+	}
+	return p.plan(p.indices(feed.withChildren([]*Stop{from})), p.indices(feed.withChildren([]*Stop{to}))), nil
+}
+
+// tripRun is a trip running on one service day, with its times as Unix seconds
+type tripRun struct {
+	trip       *Trip
+	stopTimes  []StopTime
+	arrivals   []int64
+	departures []int64
+}
+
+// journeyPattern is a sequence of stops with the runs calling at all of them, ordered by departure. Runs of a
+// pattern don't overtake each other, so the first run leaving a stop after some moment also arrives first further on.
+type journeyPattern struct {
+	stops []int
+	runs  []*tripRun
+}
+
+type patternStop struct {
+	pattern  int
+	position int
+}
+
+type footpath struct {
+	to       int
+	duration int64
+}
+
+// journeyLabel tells how and when a stop is reached in a round
+type journeyLabel struct {
+	arrival int64
+	ready   int64 // moment another trip can be boarded, after changing
+	round   int
+
+	run           *tripRun // set when the stop is reached by a trip, boarded and alighted at these positions
+	board, alight int
+
+	walked bool // set when the stop is reached by walking from stop from
+	from   int
+}
+
+type planner struct {
+	feed     *Feed
+	options  JourneyOptions
+	location *time.Location
+	depart   int64
+
+	stopIds      []string
+	stopIndex    map[string]int
+	patterns     []*journeyPattern
+	stopPatterns [][]patternStop
+	changeTimes  map[int]int64
+	footpaths    map[int][]footpath
+
+	labels        [][]journeyLabel // per round
+	best          []int64
+	targets       map[int]bool
+	targetArrival int64
+}
+
+// newPlanner builds the patterns of the trips that run around depart, on the service day before, of and after it
+func newPlanner(feed *Feed, depart time.Time, options JourneyOptions) (*planner, error) {
+	calendar, err := feed.ServiceCalendar()
+	if err != nil {
+		return nil, err
+	}
+	p := &planner{
+		feed:        feed,
+		options:     options,
+		location:    depart.Location(),
+		depart:      depart.Unix(),
+		stopIndex:   make(map[string]int, len(feed.Store.Stop)),
+		changeTimes: map[int]int64{},
+		footpaths:   map[int][]footpath{},
+	}
+	for _, stop := range feed.Store.Stop {
+		p.index(stop.Id)
+	}
+
+	runs := map[string][]*tripRun{}
+	keys := make([]string, 0)
+	stops := map[string][]int{}
+	locations := map[string]*time.Location{}
+	for i := range feed.Store.Trip {
+		trip := &feed.Store.Trip[i]
+		stopTimes := feed.TripStopTimes(trip.TripId)
+		if len(stopTimes) < 2 {
+			continue
+		}
+		location, err := feed.tripLocation(trip, p.location, locations)
+		if err != nil {
+			return nil, err
+		}
+
+		for offset := -1; offset <= 1; offset++ {
+			date := depart.In(location).AddDate(0, 0, offset)
+			if !calendar.IsActive(trip.ServiceId, date) {
+				continue
+			}
+			run := newTripRun(trip, stopTimes, date, location)
+			if run == nil || run.arrivals[len(run.arrivals)-1] < p.depart {
+				continue
+			}
+
+			ids := make([]string, len(stopTimes))
+			for k, stopTime := range stopTimes {
+				ids[k] = stopTime.StopId
+			}
+			key := strings.Join(ids, "\x00")
+			if _, ok := runs[key]; !ok {
+				keys = append(keys, key)
+				stops[key] = p.indices(nil, ids...)
+			}
+			runs[key] = append(runs[key], run)
+		}
+	}
+	for _, key := range keys {
+		p.addPatterns(stops[key], runs[key])
+	}
+
+	p.stopPatterns = make([][]patternStop, len(p.stopIds))
+	for i, pattern := range p.patterns {
+		for position, stop := range pattern.stops {
+			p.stopPatterns[stop] = append(p.stopPatterns[stop], patternStop{i, position})
+		}
+	}
+	p.addTransfers()
+	return p, nil
+}
+
+// newTripRun returns the times of a trip on the service day date, or nil when its first or last stop is untimed
+func newTripRun(trip *Trip, stopTimes []StopTime, date time.Time, location *time.Location) *tripRun {
+	run := &tripRun{trip: trip, stopTimes: stopTimes, arrivals: make([]int64, len(stopTimes)), departures: make([]int64, len(stopTimes))}
+	for i, stopTime := range stopTimes {
+		arrival, departure := stopTime.ArrivalTime, stopTime.DepartureTime
+		if !arrival.IsSet() {
+			arrival = departure
+		}
+		if !departure.IsSet() {
+			departure = arrival
+		}
+		run.arrivals[i], run.departures[i] = never, never
+		if arrival.IsSet() {
+			run.arrivals[i], run.departures[i] = arrival.On(date, location).Unix(), departure.On(date, location).Unix()
+		}
+	}
+
+	// stops between timed stops are given evenly spaced times, as they may be boarded or left too
+	last := len(stopTimes) - 1
+	if run.arrivals[0] == never || run.arrivals[last] == never {
+		return nil
+	}
+	previous := 0
+	for i := 1; i <= last; i++ {
+		if run.arrivals[i] == never {
+			continue
+		}
+		for j := previous + 1; j < i; j++ {
+			t := run.departures[previous] + (run.arrivals[i]-run.departures[previous])*int64(j-previous)/int64(i-previous)
+			run.arrivals[j], run.departures[j] = t, t
+		}
+		previous = i
+	}
+	return run
+}
+
+// index returns the index of a stop, adding it when it isn't known yet
+func (p *planner) index(stopId string) int {
+	if i, ok := p.stopIndex[stopId]; ok {
+		return i
+	}
+	p.stopIndex[stopId] = len(p.stopIds)
+	p.stopIds = append(p.stopIds, stopId)
+	return len(p.stopIds) - 1
+}
+
+// indices returns the indices of the given stops and stop IDs
+func (p *planner) indices(stops []*Stop, stopIds ...string) []int {
+	indices := make([]int, 0, len(stops)+len(stopIds))
+	for _, stop := range stops {
+		indices = append(indices, p.index(stop.Id))
+	}
+	for _, stopId := range stopIds {
+		indices = append(indices, p.index(stopId))
+	}
+	return indices
+}
+
+// addPatterns splits the runs calling at the same stops into patterns in which no run overtakes another
+func (p *planner) addPatterns(stops []int, runs []*tripRun) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].departures[0] < runs[j].departures[0]
+	})
+
+	patterns := make([]*journeyPattern, 0, 1)
+	for _, run := range runs {
+		var pattern *journeyPattern
+		for _, candidate := range patterns {
+			if !overtakes(run, candidate.runs[len(candidate.runs)-1]) {
+				pattern = candidate
+				break
+			}
+		}
+		if pattern == nil {
+			pattern = &journeyPattern{stops: stops}
+			patterns = append(patterns, pattern)
+		}
+		pattern.runs = append(pattern.runs, run)
+	}
+	p.patterns = append(p.patterns, patterns...)
+}
+
+// overtakes tells if run arrives at or leaves any stop before other
+func overtakes(run, other *tripRun) bool {
+	for i := range run.arrivals {
+		if run.arrivals[i] < other.arrivals[i] || run.departures[i] < other.departures[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// addTransfers reads the change times at stops and footpaths between stops from transfers.txt, and connects the
+// platforms of each station that transfers.txt leaves out
+func (p *planner) addTransfers() {
+	given := map[[2]int]bool{}
+	for _, transfer := range p.feed.Store.Transfer {
+		if transfer.FromRouteId != "" || transfer.ToRouteId != "" || transfer.FromTripId != "" || transfer.ToTripId != "" {
+			continue
+		}
+		from, ok := p.stopIndex[transfer.FromStopId]
+		if !ok {
+			continue
+		}
+		to, ok := p.stopIndex[transfer.ToStopId]
+		if !ok {
+			continue
+		}
+
+		given[[2]int{from, to}] = true
+
+		duration := int64(transfer.MinTransferTime)
+		if from == to {
+			switch {
+			case transfer.TransferType == transferNotPossible:
+				p.changeTimes[from] = never
+			case transfer.TransferType == 1 || duration > 0:
+				// a timed transfer waits for passengers
+				p.changeTimes[from] = duration
+			}
+			continue
+		}
+		if transfer.TransferType == transferNotPossible {
+			continue
+		}
+		if duration == 0 {
+			a, b := p.feed.Stop(transfer.FromStopId), p.feed.Stop(transfer.ToStopId)
+			if a == nil || b == nil {
+				continue
+			}
+			duration = int64(math.Ceil(distance(a.Lat, a.Lon, b.Lat, b.Lon) / walkingSpeed))
+		}
+		p.footpaths[from] = append(p.footpaths[from], footpath{to, duration})
+	}
+
+	platforms := map[string][]int{}
+	for _, stop := range p.feed.Store.Stop {
+		if stop.LocationType == 0 && stop.ParentStation != "" {
+			platforms[stop.ParentStation] = append(platforms[stop.ParentStation], p.stopIndex[stop.Id])
+		}
+	}
+	change := int64(p.options.ChangeTime / time.Second)
+	for _, station := range platforms {
+		for _, from := range station {
+			for _, to := range station {
+				if from != to && !given[[2]int{from, to}] {
+					p.footpaths[from] = append(p.footpaths[from], footpath{to, change})
+				}
+			}
+		}
+	}
+}
+
+// ready returns when another trip can be boarded after arriving at a stop by trip
+func (p *planner) ready(stop int, arrival int64) int64 {
+	change, ok := p.changeTimes[stop]
+	if !ok {
+		change = int64(p.options.ChangeTime / time.Second)
+	}
+	if change == never {
+		return never
+	}
+	return arrival + change
+}
+
+// reach records an improved arrival at a stop
+func (p *planner) reach(stop int, arrival int64) {
+	p.best[stop] = arrival
+	if p.targets[stop] && arrival < p.targetArrival {
+		p.targetArrival = arrival
+	}
+}
+
+// plan runs the rounds of RAPTOR: round k finds the earliest arrivals using k trips
+func (p *planner) plan(origins, targets []int) []Journey {
+	p.best = make([]int64, len(p.stopIds))
+	first := make([]journeyLabel, len(p.stopIds))
+	for i := range first {
+		p.best[i] = never
+		first[i] = journeyLabel{arrival: never, ready: never}
+	}
+	p.labels = [][]journeyLabel{first}
+	p.targets = map[int]bool{}
+	for _, stop := range targets {
+		p.targets[stop] = true
+	}
+	p.targetArrival = never
+
+	marked := make([]int, 0, len(origins))
+	for _, stop := range origins {
+		if first[stop].arrival == never {
+			first[stop] = journeyLabel{arrival: p.depart, ready: p.depart}
+			p.reach(stop, p.depart)
+			marked = append(marked, stop)
+		}
+	}
+	marked = append(marked, p.walk(0, marked)...)
+	journeys := p.collect(0, targets, nil)
+
+	for k := 1; k <= p.options.MaxTransfers+1 && len(marked) > 0; k++ {
+		p.labels = append(p.labels, append([]journeyLabel(nil), p.labels[k-1]...))
+		marked = p.scan(k, marked)
+		marked = append(marked, p.walk(k, marked)...)
+		journeys = p.collect(k, targets, journeys)
+	}
+	return paretoJourneys(journeys)
+}
+
+// scan rides the patterns calling at the stops reached in the previous round, returning the stops reached earlier
+func (p *planner) scan(k int, marked []int) []int {
+	starts := map[int]int{}
+	for _, stop := range marked {
+		for _, at := range p.stopPatterns[stop] {
+			if start, ok := starts[at.pattern]; !ok || at.position < start {
+				starts[at.pattern] = at.position
+			}
+		}
+	}
+	patterns := make([]int, 0, len(starts))
+	for pattern := range starts {
+		patterns = append(patterns, pattern)
+	}
+	sort.Ints(patterns)
+
+	previous, labels := p.labels[k-1], p.labels[k]
+	improved := make([]int, 0)
+	for _, i := range patterns {
+		pattern := p.patterns[i]
+		var run *tripRun
+		board := 0
+		for position := starts[i]; position < len(pattern.stops); position++ {
+			stop := pattern.stops[position]
+			if run != nil && run.stopTimes[position].DropOffType != PickupNone {
+				arrival := run.arrivals[position]
+				if arrival < p.best[stop] && arrival < p.targetArrival {
+					if labels[stop].round != k {
+						improved = append(improved, stop)
+					}
+					labels[stop] = journeyLabel{arrival: arrival, ready: p.ready(stop, arrival), round: k, run: run, board: board, alight: position}
+					p.reach(stop, arrival)
+				}
+			}
+
+			if previous[stop].ready != never && position < len(pattern.stops)-1 {
+				next := pattern.earliestRun(position, previous[stop].ready)
+				if next != nil && (run == nil || next.departures[position] < run.departures[position]) {
+					run, board = next, position
+				}
+			}
+		}
+	}
+	return improved
+}
+
+// earliestRun returns the first run that can be boarded at a position at or after ready, or nil
+func (pattern *journeyPattern) earliestRun(position int, ready int64) *tripRun {
+	i := sort.Search(len(pattern.runs), func(i int) bool {
+		return pattern.runs[i].departures[position] >= ready
+	})
+	for ; i < len(pattern.runs); i++ {
+		if pattern.runs[i].stopTimes[position].PickUpType != PickupNone {
+			return pattern.runs[i]
+		}
+	}
+	return nil
+}
+
+// walk follows the footpaths from the stops reached by trip in round k, returning the stops reached earlier by walking.
+// Stops reached by trip in the same round keep that label, so journeys never walk twice in a row.
+func (p *planner) walk(k int, marked []int) []int {
+	labels := p.labels[k]
+	walked := make([]int, 0)
+	for _, stop := range marked {
+		for _, path := range p.footpaths[stop] {
+			arrival := labels[stop].arrival + path.duration
+			target := labels[path.to]
+			if arrival >= p.best[path.to] || arrival >= p.targetArrival || (target.round == k && !target.walked && target.arrival != never) {
+				continue
+			}
+			if !target.walked || target.round != k {
+				walked = append(walked, path.to)
+			}
+			labels[path.to] = journeyLabel{arrival: arrival, ready: arrival, round: k, walked: true, from: stop}
+			p.reach(path.to, arrival)
+		}
+	}
+	return walked
+}
+
+// collect adds the journey to the earliest target reached in round k
+func (p *planner) collect(k int, targets []int, journeys []Journey) []Journey {
+	best := -1
+	for _, stop := range targets {
+		label := p.labels[k][stop]
+		if label.arrival != never && label.round == k && (best == -1 || label.arrival < p.labels[k][best].arrival) {
+			best = stop
+		}
+	}
+	if best == -1 {
+		return journeys
+	}
+	return append(journeys, p.journey(k, best))
+}
+
+// journey follows the labels back from a stop reached in round k to the origin
+func (p *planner) journey(k, stop int) Journey {
+	legs := make([]Leg, 0)
+	label := p.labels[k][stop]
+	for label.run != nil || label.walked {
+		if label.walked {
+			from := p.labels[label.round][label.from]
+			legs = append(legs, Leg{
+				Mode:       LegWalk,
+				FromStopId: p.stopIds[label.from],
+				ToStopId:   p.stopIds[stop],
+				Departure:  p.time(from.arrival),
+				Arrival:    p.time(label.arrival),
+			})
+			stop, label = label.from, from
+			continue
+		}
+
+		run := label.run
+		leg := Leg{
+			Mode:       LegTransit,
+			FromStopId: run.stopTimes[label.board].StopId,
+			ToStopId:   run.stopTimes[label.alight].StopId,
+			Departure:  p.time(run.departures[label.board]),
+			Arrival:    p.time(run.arrivals[label.alight]),
+			TripId:     run.trip.TripId,
+			RouteId:    run.trip.RouteId,
+			Headsign:   run.stopTimes[label.board].StopHeadsign,
+		}
+		if leg.Headsign == "" {
+			leg.Headsign = run.trip.TripHeadsign
+		}
+		if route := p.feed.Route(run.trip.RouteId); route != nil {
+			leg.RouteShortName = route.RouteShortName
+		}
+		legs = append(legs, leg)
+		stop = p.stopIndex[leg.FromStopId]
+		label = p.labels[label.round-1][stop]
+	}
+
+	journey := Journey{Departure: p.time(p.depart), Arrival: p.time(p.depart), Legs: legs}
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+	if len(legs) > 0 {
+		journey.Departure = legs[0].Departure
+		journey.Arrival = legs[len(legs)-1].Arrival
+	}
+	for _, leg := range legs {
+		if leg.Mode == LegTransit {
+			journey.Transfers++
+		}
+	}
+	if journey.Transfers > 0 {
+		journey.Transfers--
+	}
+	return journey
+}
+
+func (p *planner) time(unix int64) time.Time {
+	return time.Unix(unix, 0).In(p.location)
+}
+
+// paretoJourneys drops the journeys that another journey arrives no later than with no more transfers, and orders the
+// rest by number of transfers
+func paretoJourneys(journeys []Journey) []Journey {
+	result := make([]Journey, 0, len(journeys))
+	for i, journey := range journeys {
+		dominated := false
+		for j, other := range journeys {
+			if i != j && !other.Arrival.After(journey.Arrival) && other.Transfers <= journey.Transfers &&
+				(other.Arrival.Before(journey.Arrival) || other.Transfers < journey.Transfers || j < i) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			result = append(result, journey)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Transfers < result[j].Transfers
+	})
+	return result
+}
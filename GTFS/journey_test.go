@@ -0,0 +1,121 @@
+package GTFS
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJourneys(t *testing.T) {
+	store := Store{
+		Agency:   []Agency{{Id: "A", Timezone: "Europe/Amsterdam"}},
+		Calendar: []Calendar{{ServiceId: "daily", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, Saturday: 1, Sunday: 1, StartDate: "20261001", EndDate: "20261031"}},
+		Route:    []Route{{RouteId: "slow", AgencyId: "A"}, {RouteId: "feeder", AgencyId: "A"}, {RouteId: "express", AgencyId: "A"}, {RouteId: "late", AgencyId: "A"}},
+		Stop:     []Stop{{Id: "a"}, {Id: "b"}, {Id: "c"}, {Id: "m"}, {Id: "n"}},
+		Transfer: []Transfer{{FromStopId: "m", ToStopId: "n", TransferType: 2, MinTransferTime: 120}},
+	}
+	addTrip := func(routeId, tripId string, stops ...interface{}) {
+		store.Trip = append(store.Trip, Trip{RouteId: routeId, ServiceId: "daily", TripId: tripId})
+		for i := 0; i < len(stops); i += 2 {
+			at := stops[i+1].(Time)
+			store.StopTime = append(store.StopTime, StopTime{TripId: tripId, Sequence: i/2 + 1, StopId: stops[i].(string), ArrivalTime: at, DepartureTime: at})
+		}
+	}
+	addTrip("slow", "slow", "a", NewTime(8, 0, 0), "b", NewTime(8, 10, 0), "c", NewTime(8, 40, 0))
+	addTrip("feeder", "feeder", "a", NewTime(8, 0, 0), "m", NewTime(8, 5, 0))
+	addTrip("express", "express", "n", NewTime(8, 8, 0), "c", NewTime(8, 15, 0))
+	// reached from m with a change at m, but later than by walking to n
+	addTrip("late", "late", "m", NewTime(8, 8, 0), "c", NewTime(8, 20, 0))
+
+	location, _ := time.LoadLocation("Europe/Amsterdam")
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 19, hour, minute, 0, 0, location)
+	}
+	feed := NewFeed(&store)
+	journeys, err := feed.Journeys("a", "c", at(7, 55), JourneyOptions{MaxTransfers: 3, ChangeTime: 2 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Journey{
+		{Departure: at(8, 0), Arrival: at(8, 40), Transfers: 0, Legs: []Leg{
+			{Mode: LegTransit, FromStopId: "a", ToStopId: "c", Departure: at(8, 0), Arrival: at(8, 40), TripId: "slow", RouteId: "slow"},
+		}},
+		{Departure: at(8, 0), Arrival: at(8, 15), Transfers: 1, Legs: []Leg{
+			{Mode: LegTransit, FromStopId: "a", ToStopId: "m", Departure: at(8, 0), Arrival: at(8, 5), TripId: "feeder", RouteId: "feeder"},
+			{Mode: LegWalk, FromStopId: "m", ToStopId: "n", Departure: at(8, 5), Arrival: at(8, 7)},
+			{Mode: LegTransit, FromStopId: "n", ToStopId: "c", Departure: at(8, 8), Arrival: at(8, 15), TripId: "express", RouteId: "express"},
+		}},
+	}
+	if !reflect.DeepEqual(journeys, expected) {
+		t.Errorf("unexpected journeys:\n%+v\nexpected:\n%+v", journeys, expected)
+	}
+
+	// the walk to n takes too long to catch the express, and the change at m is too short for the late trip
+	store.Transfer[0].MinTransferTime = 240
+	journeys, err = NewFeed(&store).Journeys("a", "c", at(7, 55), JourneyOptions{MaxTransfers: 3, ChangeTime: 4 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journeys) != 1 || journeys[0].Arrival != at(8, 40) {
+		t.Errorf("expected only the direct journey, got %+v", journeys)
+	}
+
+	// after the last departure of the day, the journey runs the next day
+	journeys, err = feed.Journeys("a", "c", at(9, 0), JourneyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journeys) != 1 || !journeys[0].Departure.Equal(at(8, 0).AddDate(0, 0, 1)) {
+		t.Errorf("expected a journey the next day, got %+v", journeys)
+	}
+
+	if _, err := feed.Journeys("a", "missing", at(8, 0), JourneyOptions{}); !errors.Is(err, ErrUnknownStop) {
+		t.Errorf("expected ErrUnknownStop, got %v", err)
+	}
+}
+
+func TestJourneysWithinStation(t *testing.T) {
+	store := Store{
+		Calendar: []Calendar{{ServiceId: "daily", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, Saturday: 1, Sunday: 1, StartDate: "20261001", EndDate: "20261031"}},
+		Route:    []Route{{RouteId: "r"}},
+		Stop: []Stop{
+			{Id: "a"}, {Id: "c"},
+			{Id: "s", LocationType: 1}, {Id: "s1", ParentStation: "s"}, {Id: "s2", ParentStation: "s"},
+		},
+		Trip: []Trip{{RouteId: "r", ServiceId: "daily", TripId: "in"}, {RouteId: "r", ServiceId: "daily", TripId: "out"}},
+		StopTime: []StopTime{
+			{TripId: "in", Sequence: 1, StopId: "a", ArrivalTime: NewTime(8, 0, 0), DepartureTime: NewTime(8, 0, 0)},
+			{TripId: "in", Sequence: 2, StopId: "s1", ArrivalTime: NewTime(8, 5, 0), DepartureTime: NewTime(8, 5, 0)},
+			{TripId: "out", Sequence: 1, StopId: "s2", ArrivalTime: NewTime(8, 10, 0), DepartureTime: NewTime(8, 10, 0)},
+			{TripId: "out", Sequence: 2, StopId: "c", ArrivalTime: NewTime(8, 20, 0), DepartureTime: NewTime(8, 20, 0)},
+		},
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 19, hour, minute, 0, 0, time.UTC)
+	}
+
+	// transfers.txt has no row for the change from platform s1 to s2, which takes the change time
+	journeys, err := NewFeed(&store).Journeys("a", "c", at(7, 55), JourneyOptions{MaxTransfers: 1, ChangeTime: 3 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Journey{{Departure: at(8, 0), Arrival: at(8, 20), Transfers: 1, Legs: []Leg{
+		{Mode: LegTransit, FromStopId: "a", ToStopId: "s1", Departure: at(8, 0), Arrival: at(8, 5), TripId: "in", RouteId: "r"},
+		{Mode: LegWalk, FromStopId: "s1", ToStopId: "s2", Departure: at(8, 5), Arrival: at(8, 8)},
+		{Mode: LegTransit, FromStopId: "s2", ToStopId: "c", Departure: at(8, 10), Arrival: at(8, 20), TripId: "out", RouteId: "r"},
+	}}}
+	if !reflect.DeepEqual(journeys, expected) {
+		t.Errorf("unexpected journeys:\n%+v\nexpected:\n%+v", journeys, expected)
+	}
+
+	// a change that takes longer misses the connection
+	journeys, err = NewFeed(&store).Journeys("a", "c", at(7, 55), JourneyOptions{MaxTransfers: 1, ChangeTime: 6 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journeys) != 1 || !journeys[0].Arrival.Equal(at(8, 20).AddDate(0, 0, 1)) {
+		t.Errorf("expected to arrive with the trip of the next day, got %+v", journeys)
+	}
+}
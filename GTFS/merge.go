@@ -0,0 +1,435 @@
+package GTFS
+
+import (
+	"fmt"
+	"sort"
+)
+
+type MergeOptions struct {
+	Prefix string // prepended to IDs of the merged feed that collide with IDs already in the store; without one, a number is appended
+	Dedupe bool   // when set, colliding rows that are identical apart from their ID are merged into one instead of renamed
+}
+
+// MergeStats counts per file type how many colliding IDs of the merged feed were renamed and how many were merged
+// into an identical row of the store
+type MergeStats struct {
+	Renamed map[string]int
+	Merged  map[string]int
+}
+
+// merger merges one feed into a store, keeping track of the new ID of every ID of the merged feed
+type merger struct {
+	store   *Store
+	other   *Store
+	options MergeOptions
+	stats   MergeStats
+
+	agencies map[string]string
+	stops    map[string]string
+	routes   map[string]string
+	shapes   map[string]string
+	services map[string]string
+	trips    map[string]string
+}
+
+// Merge adds the contents of other to the store, leaving other itself unchanged. IDs of other that are already used in
+// the store are either merged, when Dedupe is set and both rows are identical, or renamed by prepending Prefix;
+// references are updated accordingly. With Dedupe, stops are also merged into a stop of the store with another ID at
+// the same coordinates, with the same values and in the same parent station.
+// A feed with a single agency without ID, either the store or other, gets the agency name as agency_id.
+func (store *Store) Merge(other *Store, options MergeOptions) MergeStats {
+	// a feed with a single agency may leave agency_id out, which becomes ambiguous once feeds are combined
+	if len(store.Agency) > 0 || len(store.Route) > 0 {
+		store.nameAgency()
+	}
+	named := *other
+	named.Agency = append([]Agency(nil), other.Agency...)
+	named.Route = append([]Route(nil), other.Route...)
+	named.nameAgency()
+
+	m := &merger{
+		store:    store,
+		other:    &named,
+		options:  options,
+		stats:    MergeStats{Renamed: map[string]int{}, Merged: map[string]int{}},
+		agencies: map[string]string{},
+		stops:    map[string]string{},
+		routes:   map[string]string{},
+		shapes:   map[string]string{},
+		services: map[string]string{},
+		trips:    map[string]string{},
+	}
+
+	m.mergeAgencies()
+	m.mergeStops()
+	m.mergeRoutes()
+	m.mergeShapes()
+	m.mergeServices()
+	m.mergeTrips()
+	m.mergeTransfers()
+	return m.stats
+}
+
+// nameAgency gives the only agency of a feed an ID when it has none, and assigns its routes to it
+func (store *Store) nameAgency() {
+	if len(store.Agency) != 1 || store.Agency[0].Id != "" {
+		return
+	}
+	store.Agency[0].Id = store.Agency[0].Name
+	if store.Agency[0].Id == "" {
+		store.Agency[0].Id = "agency"
+	}
+	for i := range store.Route {
+		if store.Route[i].AgencyId == "" {
+			store.Route[i].AgencyId = store.Agency[0].Id
+		}
+	}
+}
+
+// resolve decides the ID an entity of the merged feed gets. taken tells whether an ID is in use in the store, and
+// identical whether the entity equals the one with the same ID in the store. ok is false when the entity is merged
+// into the existing one and shouldn't be added.
+func (m *merger) resolve(fileType, id string, taken func(string) bool, identical func() bool) (newId string, ok bool) {
+	if !taken(id) {
+		return id, true
+	}
+	if m.options.Dedupe && identical() {
+		m.stats.Merged[fileType]++
+		return id, false
+	}
+
+	m.stats.Renamed[fileType]++
+	if m.options.Prefix == "" {
+		for n := 2; ; n++ {
+			if newId = fmt.Sprintf("%s_%d", id, n); !taken(newId) {
+				return newId, true
+			}
+		}
+	}
+	newId = m.options.Prefix + id
+	for taken(newId) {
+		newId = m.options.Prefix + newId
+	}
+	return newId, true
+}
+
+// mapped returns the new ID of a reference, leaving empty references and unknown IDs alone
+func mapped(ids map[string]string, id string) string {
+	if newId, ok := ids[id]; ok {
+		return newId
+	}
+	return id
+}
+
+func (m *merger) mergeAgencies() {
+	existing := map[string]Agency{}
+	for _, agency := range m.store.Agency {
+		existing[agency.Id] = agency
+	}
+	taken := func(id string) bool {
+		_, ok := existing[id]
+		return ok
+	}
+
+	for _, agency := range m.other.Agency {
+		newId, ok := m.resolve("agency", agency.Id, taken, func() bool {
+			return agency == existing[agency.Id]
+		})
+		m.agencies[agency.Id] = newId
+		if ok {
+			agency.Id = newId
+			existing[newId] = agency
+			m.store.Agency = append(m.store.Agency, agency)
+		}
+	}
+}
+
+func (m *merger) mergeStops() {
+	existing := map[string]Stop{}
+	for _, stop := range m.store.Stop {
+		existing[stop.Id] = stop
+	}
+	taken := func(id string) bool {
+		_, ok := existing[id]
+		return ok
+	}
+	// identical stops lie at the same coordinates, have the same values and belong to the same station, whatever their ID
+	byValues := map[Stop]string{}
+	for i := len(m.store.Stop) - 1; i >= 0; i-- { // backwards, so the first of several identical stops is found
+		byValues[stopValues(m.store.Stop[i])] = m.store.Stop[i].Id
+	}
+
+	// parent stations are resolved before the stops inside them, so stops are compared within the station their parent
+	// became, and every ID is resolved before stops are added in their original order
+	order := stopsByDepth(m.other.Stop)
+	added := make([]*Stop, len(m.other.Stop))
+	for _, i := range order {
+		stop := m.other.Stop[i]
+		stop.ParentStation = mapped(m.stops, stop.ParentStation)
+		identical := func() bool {
+			return stopValues(existing[stop.Id]) == stopValues(stop)
+		}
+		// an identical stop under the same ID is merged by resolve, one under another ID here
+		if same, ok := byValues[stopValues(stop)]; ok && m.options.Dedupe && !(taken(stop.Id) && identical()) {
+			m.stats.Merged["stops"]++
+			m.stops[stop.Id] = same
+			continue
+		}
+
+		newId, ok := m.resolve("stops", stop.Id, taken, identical)
+		m.stops[stop.Id] = newId
+		if ok {
+			stop.Id = newId
+			existing[newId] = stop
+			added[i] = &stop
+		}
+	}
+
+	for _, stop := range added {
+		if stop != nil {
+			m.store.Stop = append(m.store.Stop, *stop)
+		}
+	}
+}
+
+// stopsByDepth returns the indices of stops ordered by the number of parent stations above them, stations first
+func stopsByDepth(stops []Stop) []int {
+	byId := stopsById(stops)
+	depths := make([]int, len(stops))
+	order := make([]int, len(stops))
+	for i, stop := range stops {
+		order[i] = i
+		// stops can't be nested deeper than there are stops, which ends loops of parent stations
+		for parent := stop.ParentStation; parent != "" && depths[i] < len(stops); parent = byId[parent].ParentStation {
+			depths[i]++
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return depths[order[a]] < depths[order[b]]
+	})
+	return order
+}
+
+// stopValues returns a stop without its ID and the text it was read with, to compare stops by their values
+func stopValues(stop Stop) Stop {
+	stop.Id, stop.raw = "", ""
+	return stop
+}
+
+func (m *merger) mergeRoutes() {
+	existing := map[string]Route{}
+	for _, route := range m.store.Route {
+		existing[route.RouteId] = route
+	}
+	taken := func(id string) bool {
+		_, ok := existing[id]
+		return ok
+	}
+
+	for _, route := range m.other.Route {
+		route.AgencyId = mapped(m.agencies, route.AgencyId)
+		newId, ok := m.resolve("routes", route.RouteId, taken, func() bool {
+			return route == existing[route.RouteId]
+		})
+		m.routes[route.RouteId] = newId
+		if ok {
+			route.RouteId = newId
+			existing[newId] = route
+			m.store.Route = append(m.store.Route, route)
+		}
+	}
+}
+
+func (m *merger) mergeShapes() {
+	_, existing := shapesById(m.store.Shape)
+	taken := func(id string) bool {
+		_, ok := existing[id]
+		return ok
+	}
+
+	ids, shapes := shapesById(m.other.Shape)
+	for _, id := range ids {
+		points := shapes[id]
+		newId, ok := m.resolve("shapes", id, taken, func() bool {
+			return samePoints(points, existing[id])
+		})
+		m.shapes[id] = newId
+		if ok {
+			for _, point := range points {
+				point.Id = newId
+				m.store.Shape = append(m.store.Shape, point)
+			}
+			existing[newId] = points
+		}
+	}
+}
+
+func samePoints(a, b []Shape) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// service holds the calendar rows of one service, which are compared as a whole
+type service struct {
+	calendar []Calendar
+	dates    []CalendarDate
+}
+
+func servicesById(store *Store) ([]string, map[string]*service) {
+	ids := make([]string, 0)
+	services := map[string]*service{}
+	get := func(id string) *service {
+		if services[id] == nil {
+			ids = append(ids, id)
+			services[id] = &service{}
+		}
+		return services[id]
+	}
+
+	for _, calendar := range store.Calendar {
+		s := get(calendar.ServiceId)
+		s.calendar = append(s.calendar, calendar)
+	}
+	for _, calendarDate := range store.CalendarDates {
+		s := get(calendarDate.ServiceId)
+		s.dates = append(s.dates, calendarDate)
+	}
+	for _, s := range services {
+		sort.SliceStable(s.dates, func(i, j int) bool {
+			return s.dates[i].Date < s.dates[j].Date
+		})
+	}
+	return ids, services
+}
+
+func (s *service) equals(other *service) bool {
+	if other == nil || len(s.calendar) != len(other.calendar) || len(s.dates) != len(other.dates) {
+		return false
+	}
+	for i := range s.calendar {
+		if s.calendar[i] != other.calendar[i] {
+			return false
+		}
+	}
+	for i := range s.dates {
+		if s.dates[i] != other.dates[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *merger) mergeServices() {
+	_, existing := servicesById(m.store)
+	taken := func(id string) bool {
+		return existing[id] != nil
+	}
+
+	ids, services := servicesById(m.other)
+	for _, id := range ids {
+		s := services[id]
+		newId, ok := m.resolve("service", id, taken, func() bool {
+			return s.equals(existing[id])
+		})
+		m.services[id] = newId
+		if !ok {
+			continue
+		}
+
+		for _, calendar := range s.calendar {
+			calendar.ServiceId = newId
+			m.store.Calendar = append(m.store.Calendar, calendar)
+		}
+		for _, calendarDate := range s.dates {
+			calendarDate.ServiceId = newId
+			m.store.CalendarDates = append(m.store.CalendarDates, calendarDate)
+		}
+		existing[newId] = s
+	}
+}
+
+func (m *merger) mergeTrips() {
+	existing := map[string]Trip{}
+	for _, trip := range m.store.Trip {
+		existing[trip.TripId] = trip
+	}
+	taken := func(id string) bool {
+		_, ok := existing[id]
+		return ok
+	}
+
+	var existingStopTimes map[string][]StopTime // only needed to compare colliding trips
+	_, otherStopTimes := stopTimesByTrip(m.other.StopTime)
+
+	for _, trip := range m.other.Trip {
+		trip.RouteId = mapped(m.routes, trip.RouteId)
+		trip.ServiceId = mapped(m.services, trip.ServiceId)
+		trip.ShapeId = mapped(m.shapes, trip.ShapeId)
+
+		stopTimes := otherStopTimes[trip.TripId]
+		for i := range stopTimes {
+			stopTimes[i].StopId = mapped(m.stops, stopTimes[i].StopId)
+		}
+
+		newId, ok := m.resolve("trips", trip.TripId, taken, func() bool {
+			if trip != existing[trip.TripId] {
+				return false
+			}
+			if existingStopTimes == nil {
+				_, existingStopTimes = stopTimesByTrip(m.store.StopTime)
+			}
+			return sameStopTimes(stopTimes, existingStopTimes[trip.TripId])
+		})
+		m.trips[trip.TripId] = newId
+		if !ok {
+			continue
+		}
+
+		trip.TripId = newId
+		existing[newId] = trip
+		m.store.Trip = append(m.store.Trip, trip)
+		for _, stopTime := range stopTimes {
+			stopTime.TripId = newId
+			m.store.StopTime = append(m.store.StopTime, stopTime)
+		}
+	}
+}
+
+func sameStopTimes(a, b []StopTime) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *merger) mergeTransfers() {
+	existing := map[Transfer]bool{}
+	for _, transfer := range m.store.Transfer {
+		existing[transfer] = true
+	}
+
+	for _, transfer := range m.other.Transfer {
+		transfer.FromStopId = mapped(m.stops, transfer.FromStopId)
+		transfer.ToStopId = mapped(m.stops, transfer.ToStopId)
+		transfer.FromRouteId = mapped(m.routes, transfer.FromRouteId)
+		transfer.ToRouteId = mapped(m.routes, transfer.ToRouteId)
+		transfer.FromTripId = mapped(m.trips, transfer.FromTripId)
+		transfer.ToTripId = mapped(m.trips, transfer.ToTripId)
+		if !existing[transfer] {
+			existing[transfer] = true
+			m.store.Transfer = append(m.store.Transfer, transfer)
+		}
+	}
+}
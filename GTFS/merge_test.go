@@ -0,0 +1,112 @@
+package GTFS
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	store := testStore()
+	other := testStore()
+	other.Stop[0].Name = "Renamed stop"
+
+	stats := store.Merge(&other, MergeOptions{Prefix: "b_", Dedupe: true})
+
+	if stats.Renamed["stops"] != 1 || stats.Merged["stops"] != 1 || stats.Merged["trips"] != 1 || stats.Renamed["trips"] != 1 || stats.Merged["agency"] != 2 {
+		t.Errorf("unexpected merge stats %+v", stats)
+	}
+	if len(store.Stop) != 3 || store.Stop[2].Id != "b_"+other.Stop[0].Id {
+		t.Fatalf("expected the changed stop to be added with a prefix, got %+v", store.Stop)
+	}
+
+	// the trip calling at the changed stop differs as well, so it is added next to the original
+	for _, stopTime := range store.StopTime {
+		if stopTime.TripId == "b_t1" && stopTime.Sequence == 1 && stopTime.StopId != "b_a" {
+			t.Errorf("expected the renamed trip to call at the renamed stop, got %+v", stopTime)
+		}
+	}
+	if len(store.StopTime) != 4 {
+		t.Errorf("expected the stop times of the renamed trip to be added, got %d stop times", len(store.StopTime))
+	}
+
+	fresh := testStore()
+	stats = fresh.Merge(&Store{Agency: []Agency{{Id: fresh.Agency[0].Id, Name: "Other"}}, Route: []Route{{RouteId: "x", AgencyId: fresh.Agency[0].Id}}}, MergeOptions{Prefix: "b_"})
+	if stats.Renamed["agency"] != 1 || fresh.Route[len(fresh.Route)-1].AgencyId != "b_"+fresh.Agency[0].Id {
+		t.Errorf("expected the colliding agency and its route reference to be renamed, got %+v", fresh.Route)
+	}
+}
+
+func TestMergeDedupeByValues(t *testing.T) {
+	store := Store{
+		Stop:     []Stop{{Id: "1001", Name: "Centraal", Lat: 52.378, Lon: 4.9}, {Id: "1002", Name: "Zuid", Lat: 52.339, Lon: 4.873}},
+		Trip:     []Trip{{RouteId: "r", ServiceId: "s", TripId: "t"}},
+		StopTime: []StopTime{{TripId: "t", Sequence: 1, StopId: "1001"}},
+	}
+	other := Store{
+		Stop: []Stop{
+			{Id: "ams-c", Name: "Centraal", Lat: 52.378, Lon: 4.9}, // same stop under another ID
+			{Id: "1002", Name: "Zuid", Lat: 52.34, Lon: 4.873},     // colliding ID at other coordinates
+			{Id: "ams-z", Name: "Zuid", Lat: 52.3391, Lon: 4.873},  // other coordinates under another ID
+		},
+		Trip:     []Trip{{RouteId: "r", ServiceId: "s", TripId: "u"}},
+		StopTime: []StopTime{{TripId: "u", Sequence: 1, StopId: "ams-c"}, {TripId: "u", Sequence: 2, StopId: "1002"}},
+	}
+
+	stats := store.Merge(&other, MergeOptions{Dedupe: true})
+	if stats.Merged["stops"] != 1 || stats.Renamed["stops"] != 1 {
+		t.Errorf("unexpected merge stats %+v", stats)
+	}
+	stopIds := make([]string, 0)
+	for _, stop := range store.Stop {
+		stopIds = append(stopIds, stop.Id)
+	}
+	// without a prefix, colliding IDs get a number
+	if strings.Join(stopIds, ",") != "1001,1002,1002_2,ams-z" {
+		t.Errorf("unexpected stops %v", stopIds)
+	}
+	if store.StopTime[1].StopId != "1001" || store.StopTime[2].StopId != "1002_2" {
+		t.Errorf("expected stop times to refer to the merged and renamed stops, got %+v", store.StopTime[1:])
+	}
+
+	separate := Store{Stop: []Stop{{Id: "1001", Name: "Centraal", Lat: 52.378, Lon: 4.9}}}
+	stats = separate.Merge(&Store{Stop: []Stop{{Id: "ams-c", Name: "Centraal", Lat: 52.378, Lon: 4.9}}}, MergeOptions{})
+	if len(separate.Stop) != 2 || stats.Merged["stops"] != 0 {
+		t.Errorf("expected identical stops to be kept apart without Dedupe, got %+v", separate.Stop)
+	}
+}
+
+func TestMergeDedupeStations(t *testing.T) {
+	store := Store{Stop: []Stop{
+		{Id: "zuid", Name: "Zuid", Lat: 52.339, Lon: 4.873, LocationType: 1},
+		{Id: "zuid-1", Name: "Zuid", Lat: 52.339, Lon: 4.873, ParentStation: "zuid", PlatformCode: "1"},
+	}}
+	other := Store{
+		Agency: []Agency{{Name: "Other"}},
+		Route:  []Route{{RouteId: "r"}},
+		Stop: []Stop{
+			// platforms come before their stations, with the same values as the platform of the store
+			{Id: "z1", Name: "Zuid", Lat: 52.339, Lon: 4.873, ParentStation: "z", PlatformCode: "1"},
+			{Id: "n1", Name: "Zuid", Lat: 52.339, Lon: 4.873, ParentStation: "n", PlatformCode: "1"},
+			{Id: "z", Name: "Zuid", Lat: 52.339, Lon: 4.873, LocationType: 1},
+			{Id: "n", Name: "Zuid Noord", Lat: 52.34, Lon: 4.873, LocationType: 1},
+		},
+	}
+
+	stats := store.Merge(&other, MergeOptions{Dedupe: true})
+	// z is the station of the store, so its platform is merged, but the platform of another station is kept apart
+	if stats.Merged["stops"] != 2 {
+		t.Errorf("unexpected merge stats %+v", stats)
+	}
+	parents := make([]string, 0)
+	for _, stop := range store.Stop {
+		parents = append(parents, stop.Id+">"+stop.ParentStation)
+	}
+	if strings.Join(parents, ",") != "zuid>,zuid-1>zuid,n1>n,n>" {
+		t.Errorf("unexpected stops %v", parents)
+	}
+
+	// the merged feed is left as it was
+	if other.Agency[0].Id != "" || other.Route[0].AgencyId != "" || other.Stop[0].ParentStation != "z" {
+		t.Errorf("expected the merged feed to be unchanged, got %+v %+v", other.Agency, other.Route)
+	}
+}
@@ -0,0 +1,418 @@
+package GTFS
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/util"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoHeader      = errors.New("file has no header row")
+	ErrMissingColumn = errors.New("missing required column")
+	ErrInvalidInt    = errors.New("not a valid integer")
+	ErrInvalidFloat  = errors.New("not a valid number")
+	ErrInvalidTime   = errors.New("not a valid HH:MM:SS time")
+)
+
+// ParseError describes a problem in a GTFS file, pointing at the file, line and column it was found in
+type ParseError struct {
+	File   string
+	Line   int // record number in the file, the header being line 1
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	message := e.File
+	if e.Line > 0 {
+		message += ":" + strconv.Itoa(e.Line)
+	}
+	if e.Column != "" {
+		message += ": " + e.Column
+	}
+	if e.Value != "" {
+		message += fmt.Sprintf(": %q", e.Value)
+	}
+	return message + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the tables of a feed one record at a time, so even the largest files never have to be held in memory
+type Reader struct {
+	Lenient  bool          // when set, malformed values are collected in Warnings and read as zero instead of failing the file
+	Warnings []*ParseError // malformed values encountered while reading leniently
+
+	fsys     fs.FS
+	location string // where the feed was opened from, used to point at files in errors
+	closer   io.Closer
+}
+
+// OpenReader opens the feed stored at path, which is either a directory or a zip archive
+func OpenReader(path string) (*Reader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		reader, err := NewReader(os.DirFS(path))
+		if err != nil {
+			return nil, err
+		}
+		reader.location = path
+		return reader, nil
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewReader(&archive.Reader)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	reader.location = path
+	reader.closer = archive
+	return reader, nil
+}
+
+// NewReader reads the feed stored in fsys
+func NewReader(fsys fs.FS) (*Reader, error) {
+	fsys, err := feedRoot(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{fsys: fsys}, nil
+}
+
+// feedRoot returns the directory in fsys holding the feed, as some archives wrap all files in a single folder
+func feedRoot(fsys fs.FS) (fs.FS, error) {
+	if _, err := fs.Stat(fsys, "agency.txt"); err == nil {
+		return fsys, nil
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return fs.Sub(fsys, entries[0].Name())
+	}
+
+	return fsys, nil
+}
+
+// HasFile tells whether the feed contains the file of type fileType
+func (reader *Reader) HasFile(fileType string) bool {
+	_, err := fs.Stat(reader.fsys, fileType+".txt")
+	return err == nil
+}
+
+// FileSize returns the uncompressed size in bytes of the file of type fileType, or 0 when the feed doesn't contain it
+func (reader *Reader) FileSize(fileType string) int64 {
+	info, err := fs.Stat(reader.fsys, fileType+".txt")
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Close releases the archive the feed was opened from
+func (reader *Reader) Close() error {
+	if reader.closer == nil {
+		return nil
+	}
+	return reader.closer.Close()
+}
+
+func (reader *Reader) EachAgency(fn func(Agency) error) error {
+	return reader.eachRecord("agency", "agency.txt", func(p *rowParser) error {
+		agency := p.agency()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(agency)
+	})
+}
+
+func (reader *Reader) EachCalendar(fn func(Calendar) error) error {
+	return reader.eachRecord("calendar", "calendar.txt", func(p *rowParser) error {
+		calendar := p.calendar()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(calendar)
+	})
+}
+
+func (reader *Reader) EachCalendarDate(fn func(CalendarDate) error) error {
+	return reader.eachRecord("calendar_dates", "calendar_dates.txt", func(p *rowParser) error {
+		calendarDate := p.calendarDate()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(calendarDate)
+	})
+}
+
+func (reader *Reader) EachRoute(fn func(Route) error) error {
+	return reader.eachRecord("routes", "routes.txt", func(p *rowParser) error {
+		route := p.route()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(route)
+	})
+}
+
+func (reader *Reader) EachShape(fn func(Shape) error) error {
+	return reader.eachRecord("shapes", "shapes.txt", func(p *rowParser) error {
+		shape := p.shape()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(shape)
+	})
+}
+
+func (reader *Reader) EachStopTime(fn func(StopTime) error) error {
+	return reader.eachRecord("stop_times", "stop_times.txt", func(p *rowParser) error {
+		stopTime := p.stopTime()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(stopTime)
+	})
+}
+
+func (reader *Reader) EachStop(fn func(Stop) error) error {
+	return reader.eachRecord("stops", "stops.txt", func(p *rowParser) error {
+		stop := p.stop()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(stop)
+	})
+}
+
+func (reader *Reader) EachTransfer(fn func(Transfer) error) error {
+	return reader.eachRecord("transfers", "transfers.txt", func(p *rowParser) error {
+		transfer := p.transfer()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(transfer)
+	})
+}
+
+func (reader *Reader) EachTrip(fn func(Trip) error) error {
+	return reader.eachRecord("trips", "trips.txt", func(p *rowParser) error {
+		trip := p.trip()
+		if p.err != nil {
+			return p.err
+		}
+		return fn(trip)
+	})
+}
+
+// eachRecord calls fn for every record of fileName, a file of type fileType, stopping at the first error
+func (reader *Reader) eachRecord(fileType, fileName string, fn func(p *rowParser) error) error {
+	if _, ok := requiredColumns[fileType]; !ok {
+		return fmt.Errorf("unknown GTFS file type %q", fileType)
+	}
+
+	filePath := filepath.Join(reader.location, fileName)
+	gtfsFile, err := reader.fsys.Open(fileName)
+	if errors.Is(err, fs.ErrNotExist) && optionalFiles[fileType] {
+		return nil
+	}
+	if err != nil {
+		return &ParseError{File: filePath, Err: err}
+	}
+	defer gtfsFile.Close()
+
+	csvReader := csv.NewReader(gtfsFile)
+	csvReader.FieldsPerRecord = -1 // rows are matched by column name, so they don't need to be as wide as the header
+	csvReader.ReuseRecord = true
+
+	record, err := csvReader.Read()
+	if err == io.EOF {
+		return &ParseError{File: filePath, Err: ErrNoHeader}
+	}
+	if err != nil {
+		return &ParseError{File: filePath, Err: err}
+	}
+	columns, err := readHeader(filePath, fileType, record)
+	if err != nil {
+		return err
+	}
+
+	p := &rowParser{reader: reader, file: filePath, columns: columns, line: 1}
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &ParseError{File: filePath, Err: err}
+		}
+
+		p.next(record)
+		if err := fn(p); err != nil {
+			return err
+		}
+		if p.err != nil {
+			return p.err
+		}
+	}
+}
+
+// rowParser converts the values of one record, keeping track of the first malformed value in it
+type rowParser struct {
+	reader  *Reader
+	file    string
+	columns header
+	record  []string
+	line    int
+	err     error
+
+	kept     []byte               // the raw values of the record, see rawValues
+	interned map[string]rawValues // raw values seen before, as most rows of a file leave the same columns empty
+}
+
+// most distinct raw values interned per file, so values that differ on every row don't pile up
+const maxInterned = 1024
+
+func (p *rowParser) next(record []string) {
+	p.record = record
+	p.line++
+	p.kept = p.kept[:0]
+}
+
+func (p *rowParser) string(column string) string {
+	return p.columns.get(p.record, column)
+}
+
+func (p *rowParser) int(column string) int {
+	value := strings.TrimSpace(p.string(column))
+	if value == "" {
+		p.keep(column, "0")
+		return 0
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		p.invalid(column, value, ErrInvalidInt)
+		return 0
+	}
+	p.keep(column, strconv.Itoa(i))
+	return i
+}
+
+func (p *rowParser) float(column string) float64 {
+	value := strings.TrimSpace(p.string(column))
+	if value == "" {
+		p.keep(column, "0")
+		return 0
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.invalid(column, value, ErrInvalidFloat)
+		return 0
+	}
+	p.keep(column, util.ParseFloatString(f))
+	return f
+}
+
+func (p *rowParser) time(column string) Time {
+	value := p.string(column)
+	t, err := ParseTime(value)
+	if err != nil {
+		p.invalid(column, value, err)
+		return t
+	}
+	p.keep(column, t.String())
+	return t
+}
+
+// keep remembers the text of column when Export would write its value differently, as formatted
+func (p *rowParser) keep(column, formatted string) {
+	if text := p.string(column); text != formatted {
+		p.kept = append(p.kept, ';')
+		p.kept = append(p.kept, column...)
+		p.kept = append(p.kept, '=')
+		p.kept = append(p.kept, text...)
+	}
+}
+
+// raw returns the raw values kept for the record
+func (p *rowParser) raw() rawValues {
+	if len(p.kept) == 0 {
+		return ""
+	}
+	if raw, ok := p.interned[string(p.kept)]; ok {
+		return raw
+	}
+
+	raw := rawValues(p.kept)
+	if p.interned == nil {
+		p.interned = map[string]rawValues{}
+	}
+	if len(p.interned) < maxInterned {
+		p.interned[string(raw)] = raw
+	}
+	return raw
+}
+
+// rawValues holds the text of the values of a row that Export wouldn't write back the same way, as a series of
+// ";column=text" entries. Only valid numbers and times are kept, so the text never holds a semicolon. It is empty for
+// rows written as they were read, and for rows built in code.
+type rawValues string
+
+// get returns the text a row was read with for column, if it was kept
+func (raw rawValues) get(column string) (string, bool) {
+	if raw == "" {
+		return "", false
+	}
+	s := string(raw)
+	i := strings.Index(s, ";"+column+"=")
+	if i < 0 {
+		return "", false
+	}
+	s = s[i+len(column)+2:]
+	if end := strings.IndexByte(s, ';'); end >= 0 {
+		s = s[:end]
+	}
+	return s, true
+}
+
+// isSet tells whether column wasn't left empty in the row
+func (raw rawValues) isSet(column string) bool {
+	text, ok := raw.get(column)
+	return !ok || strings.TrimSpace(text) != ""
+}
+
+// invalid reports a malformed value: as a warning on the reader when it reads leniently, otherwise as the error of this file
+func (p *rowParser) invalid(column, value string, reason error) {
+	err := &ParseError{File: p.file, Line: p.line, Column: column, Value: value, Err: reason}
+	if p.reader.Lenient {
+		p.reader.Warnings = append(p.reader.Warnings, err)
+	} else if p.err == nil {
+		p.err = err
+	}
+}
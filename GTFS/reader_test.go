@@ -0,0 +1,73 @@
+package GTFS
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReaderEachStopTime(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "agency.txt", "agency_id,agency_name,agency_url,agency_timezone\nA,A,https://a.example,Europe/Amsterdam\n")
+	writeFile(t, dir, "stop_times.txt", "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n"+
+		"t1,08:00:00,08:00:00,a,1\n"+
+		"t1,08:10:00,08:11:00,b,2\n"+
+		"t1,08:20:00,noon,c,3\n")
+
+	reader, err := OpenReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// a malformed value stops a strict reader at its line, after the rows before it were passed on
+	stopIds := make([]string, 0)
+	err = reader.EachStopTime(func(stopTime StopTime) error {
+		stopIds = append(stopIds, stopTime.StopId)
+		return nil
+	})
+	var parseError *ParseError
+	if !errors.As(err, &parseError) || parseError.Line != 4 || parseError.Column != "departure_time" || !errors.Is(err, ErrInvalidTime) {
+		t.Errorf("expected an invalid time on line 4, got %v", err)
+	}
+	if !reflect.DeepEqual(stopIds, []string{"a", "b"}) {
+		t.Errorf("expected stops a and b before the error, got %v", stopIds)
+	}
+
+	reader.Lenient = true
+	stopTimes := make([]StopTime, 0)
+	if err := reader.EachStopTime(func(stopTime StopTime) error {
+		stopTimes = append(stopTimes, stopTime)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// the optional columns the file leaves out are kept as empty, so they are exported empty again
+	expected := StopTime{TripId: "t1", Sequence: 2, StopId: "b", ArrivalTime: NewTime(8, 10, 0), DepartureTime: NewTime(8, 11, 0),
+		raw: ";pickup_type=;drop_off_type=;timepoint=;shape_dist_traveled=;fare_units_traveled="}
+	if len(stopTimes) != 3 || stopTimes[1] != expected || stopTimes[2].DepartureTime != NoTime {
+		t.Errorf("unexpected stop times %+v", stopTimes)
+	}
+	if len(reader.Warnings) != 1 || reader.Warnings[0].Line != 4 {
+		t.Errorf("expected a warning on line 4, got %v", reader.Warnings)
+	}
+
+	// an error returned by the callback ends the iteration and is returned as is
+	errStop := errors.New("stop")
+	calls := 0
+	err = reader.EachStopTime(func(stopTime StopTime) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Errorf("expected the callback error after 1 call, got %v after %d", err, calls)
+	}
+
+	// optional files may be missing
+	if err := reader.EachTransfer(func(Transfer) error {
+		t.Error("unexpected transfer")
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
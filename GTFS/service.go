@@ -0,0 +1,186 @@
+package GTFS
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// values of CalendarDate.ExceptionType
+const (
+	ServiceAdded   = 1
+	ServiceRemoved = 2
+)
+
+// DateLayout is the YYYYMMDD format of dates in GTFS files
+const DateLayout = "20060102"
+
+func ParseDate(value string) (time.Time, error) {
+	return time.Parse(DateLayout, value)
+}
+
+func FormatDate(date time.Time) string {
+	return date.Format(DateLayout)
+}
+
+// ServiceCalendar tells on which dates services run, combining the weekly patterns of calendar.txt with the
+// additions and removals of calendar_dates.txt
+type ServiceCalendar struct {
+	weekly     map[string]weeklyService
+	exceptions map[string]map[int]int // exception type per day, per service
+	services   []string
+}
+
+type weeklyService struct {
+	days       [7]bool // indexed by time.Weekday
+	start, end int
+}
+
+func NewServiceCalendar(store *Store) (*ServiceCalendar, error) {
+	calendar := &ServiceCalendar{weekly: map[string]weeklyService{}, exceptions: map[string]map[int]int{}}
+	seen := map[string]bool{}
+
+	for _, row := range store.Calendar {
+		start, err := ParseDate(row.StartDate)
+		if err != nil {
+			return nil, fmt.Errorf("calendar: service %s: invalid start_date %q", row.ServiceId, row.StartDate)
+		}
+		end, err := ParseDate(row.EndDate)
+		if err != nil {
+			return nil, fmt.Errorf("calendar: service %s: invalid end_date %q", row.ServiceId, row.EndDate)
+		}
+
+		calendar.weekly[row.ServiceId] = weeklyService{
+			days: [7]bool{
+				time.Sunday:    row.Sunday == 1,
+				time.Monday:    row.Monday == 1,
+				time.Tuesday:   row.Tuesday == 1,
+				time.Wednesday: row.Wednesday == 1,
+				time.Thursday:  row.Thursday == 1,
+				time.Friday:    row.Friday == 1,
+				time.Saturday:  row.Saturday == 1,
+			},
+			start: dayNumber(start),
+			end:   dayNumber(end),
+		}
+		if !seen[row.ServiceId] {
+			seen[row.ServiceId] = true
+			calendar.services = append(calendar.services, row.ServiceId)
+		}
+	}
+
+	for _, row := range store.CalendarDates {
+		date, err := ParseDate(row.Date)
+		if err != nil {
+			return nil, fmt.Errorf("calendar_dates: service %s: invalid date %q", row.ServiceId, row.Date)
+		}
+
+		if calendar.exceptions[row.ServiceId] == nil {
+			calendar.exceptions[row.ServiceId] = map[int]int{}
+		}
+		calendar.exceptions[row.ServiceId][dayNumber(date)] = row.ExceptionType
+		if !seen[row.ServiceId] {
+			seen[row.ServiceId] = true
+			calendar.services = append(calendar.services, row.ServiceId)
+		}
+	}
+
+	sort.Strings(calendar.services)
+	return calendar, nil
+}
+
+// dayNumber counts the days between 1970-01-01 and the calendar date of date, ignoring its time and location
+func dayNumber(date time.Time) int {
+	year, month, day := date.Date()
+	return int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func dayDate(day int) time.Time {
+	return time.Unix(int64(day)*86400, 0).UTC()
+}
+
+// Services returns the IDs of all services defined in the calendar
+func (calendar *ServiceCalendar) Services() []string {
+	return calendar.services
+}
+
+// IsActive tells whether serviceId runs on the calendar date of date
+func (calendar *ServiceCalendar) IsActive(serviceId string, date time.Time) bool {
+	return calendar.activeOn(serviceId, dayNumber(date))
+}
+
+func (calendar *ServiceCalendar) activeOn(serviceId string, day int) bool {
+	if exception, ok := calendar.exceptions[serviceId][day]; ok {
+		return exception == ServiceAdded
+	}
+
+	weekly, ok := calendar.weekly[serviceId]
+	return ok && day >= weekly.start && day <= weekly.end && weekly.days[dayDate(day).Weekday()]
+}
+
+// ActiveServices returns the IDs of the services running on the calendar date of date
+func (calendar *ServiceCalendar) ActiveServices(date time.Time) []string {
+	day := dayNumber(date)
+	active := make([]string, 0)
+	for _, serviceId := range calendar.services {
+		if calendar.activeOn(serviceId, day) {
+			active = append(active, serviceId)
+		}
+	}
+	return active
+}
+
+// ServiceDates returns every date serviceId runs on, in order
+func (calendar *ServiceCalendar) ServiceDates(serviceId string) []time.Time {
+	days := calendar.serviceDays(serviceId)
+	dates := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		dates = append(dates, dayDate(day))
+	}
+	return dates
+}
+
+func (calendar *ServiceCalendar) serviceDays(serviceId string) []int {
+	days := make([]int, 0)
+	weekly, ok := calendar.weekly[serviceId]
+	if ok {
+		for day := weekly.start; day <= weekly.end; day++ {
+			if calendar.activeOn(serviceId, day) {
+				days = append(days, day)
+			}
+		}
+	}
+
+	for day, exception := range calendar.exceptions[serviceId] {
+		if exception == ServiceAdded && (!ok || day < weekly.start || day > weekly.end) {
+			days = append(days, day)
+		}
+	}
+
+	sort.Ints(days)
+	return days
+}
+
+// DateRange returns the first and last date on which any service runs; ok is false when no service runs at all
+func (calendar *ServiceCalendar) DateRange() (start, end time.Time, ok bool) {
+	first, last := 0, 0
+	for _, serviceId := range calendar.services {
+		days := calendar.serviceDays(serviceId)
+		if len(days) == 0 {
+			continue
+		}
+
+		if !ok || days[0] < first {
+			first = days[0]
+		}
+		if !ok || days[len(days)-1] > last {
+			last = days[len(days)-1]
+		}
+		ok = true
+	}
+
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	return dayDate(first), dayDate(last), true
+}
@@ -0,0 +1,70 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServiceCalendar(t *testing.T) {
+	store := Store{
+		// weekdays in the first two weeks of October 2026, which starts on a Thursday
+		Calendar: []Calendar{{ServiceId: "weekdays", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, StartDate: "20261001", EndDate: "20261014"}},
+		CalendarDates: []CalendarDate{
+			{ServiceId: "weekdays", Date: "20261005", ExceptionType: ServiceRemoved},
+			{ServiceId: "weekdays", Date: "20261010", ExceptionType: ServiceAdded},
+			{ServiceId: "weekdays", Date: "20260930", ExceptionType: ServiceAdded},
+			{ServiceId: "weekdays", Date: "20261101", ExceptionType: ServiceAdded},
+			{ServiceId: "holiday", Date: "20261005", ExceptionType: ServiceAdded},
+			{ServiceId: "cancelled", Date: "20261006", ExceptionType: ServiceRemoved},
+		},
+	}
+	calendar, err := NewServiceCalendar(&store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(value string) time.Time {
+		d, err := ParseDate(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	dates := make([]string, 0)
+	for _, d := range calendar.ServiceDates("weekdays") {
+		dates = append(dates, FormatDate(d))
+	}
+	expected := []string{"20260930", "20261001", "20261002", "20261006", "20261007", "20261008", "20261009", "20261010",
+		"20261012", "20261013", "20261014", "20261101"}
+	if !reflect.DeepEqual(dates, expected) {
+		t.Errorf("got dates %v, expected %v", dates, expected)
+	}
+	if len(calendar.ServiceDates("cancelled")) != 0 {
+		t.Errorf("expected no dates for a service that is only removed")
+	}
+
+	for day, services := range map[string][]string{
+		"20261005": {"holiday"},
+		"20261006": {"weekdays"},
+		"20261010": {"weekdays"},
+		"20261011": {},
+		"20261101": {"weekdays"},
+	} {
+		if active := calendar.ActiveServices(date(day).Add(15 * time.Hour)); !reflect.DeepEqual(active, services) {
+			t.Errorf("%s: got active services %v, expected %v", day, active, services)
+		}
+	}
+
+	start, end, ok := calendar.DateRange()
+	if !ok || FormatDate(start) != "20260930" || FormatDate(end) != "20261101" {
+		t.Errorf("got date range %v - %v (%v), expected 2026-09-30 - 2026-11-01", start, end, ok)
+	}
+	empty, err := NewServiceCalendar(&Store{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := empty.DateRange(); ok {
+		t.Error("expected no date range without services")
+	}
+}
@@ -0,0 +1,268 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// busiest days listed in Stats
+const busiestDays = 5
+
+type Stats struct {
+	Rows  map[string]int   `json:"rows"`            // rows per file type
+	Sizes map[string]int64 `json:"sizes,omitempty"` // file sizes in bytes per file type, filled in by the caller
+
+	Agencies []AgencyStats `json:"agencies"`
+
+	ServiceStart string     `json:"service_start,omitempty"`
+	ServiceEnd   string     `json:"service_end,omitempty"`
+	BusiestDays  []DayStats `json:"busiest_days"`
+
+	TripsPerRouteType   map[string]int `json:"trips_per_route_type"`
+	StopsWithoutService []string       `json:"stops_without_service"`
+
+	AverageTripLength float64 `json:"average_trip_length"` // in meters
+	TripsWithLength   int     `json:"trips_with_length"`
+}
+
+type AgencyStats struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Routes int    `json:"routes"`
+	Trips  int    `json:"trips"`
+	Stops  int    `json:"stops"`
+}
+
+type DayStats struct {
+	Date  string `json:"date"`
+	Trips int    `json:"trips"`
+}
+
+// ComputeStats summarises the contents of a store
+func ComputeStats(store *Store) (*Stats, error) {
+	stats := &Stats{
+		Rows: map[string]int{
+			"agency":         len(store.Agency),
+			"calendar":       len(store.Calendar),
+			"calendar_dates": len(store.CalendarDates),
+			"routes":         len(store.Route),
+			"shapes":         len(store.Shape),
+			"stop_times":     len(store.StopTime),
+			"stops":          len(store.Stop),
+			"transfers":      len(store.Transfer),
+			"trips":          len(store.Trip),
+		},
+		TripsPerRouteType:   map[string]int{},
+		BusiestDays:         make([]DayStats, 0),
+		StopsWithoutService: make([]string, 0),
+	}
+
+	_, stopTimes := stopTimesByTrip(store.StopTime)
+	stats.agencies(store, stopTimes)
+	if err := stats.services(store); err != nil {
+		return nil, err
+	}
+	stats.tripLengths(store, stopTimes)
+
+	served := make(map[string]bool, len(store.Stop))
+	for _, stopTime := range store.StopTime {
+		served[stopTime.StopId] = true
+	}
+	for _, stop := range store.Stop {
+		if stop.LocationType == 0 && !served[stop.Id] {
+			stats.StopsWithoutService = append(stats.StopsWithoutService, stop.Id)
+		}
+	}
+	return stats, nil
+}
+
+func (stats *Stats) agencies(store *Store, stopTimes map[string][]StopTime) {
+	index := map[string]int{}
+	stops := map[string]map[string]bool{}
+	for _, agency := range store.Agency {
+		index[agency.Id] = len(stats.Agencies)
+		stats.Agencies = append(stats.Agencies, AgencyStats{Id: agency.Id, Name: agency.Name})
+		stops[agency.Id] = map[string]bool{}
+	}
+
+	// a feed with a single agency may leave agency_id out of routes.txt
+	agencyOf := func(route Route) string {
+		if route.AgencyId == "" && len(store.Agency) == 1 {
+			return store.Agency[0].Id
+		}
+		return route.AgencyId
+	}
+
+	routes := map[string]Route{}
+	for _, route := range store.Route {
+		routes[route.RouteId] = route
+		if i, ok := index[agencyOf(route)]; ok {
+			stats.Agencies[i].Routes++
+		}
+	}
+
+	for _, trip := range store.Trip {
+		route := routes[trip.RouteId]
+		stats.TripsPerRouteType[strings.TrimSpace(route.RouteType)]++
+
+		agencyId := agencyOf(route)
+		i, ok := index[agencyId]
+		if !ok {
+			continue
+		}
+		stats.Agencies[i].Trips++
+		for _, stopTime := range stopTimes[trip.TripId] {
+			stops[agencyId][stopTime.StopId] = true
+		}
+	}
+	for agencyId, i := range index {
+		stats.Agencies[i].Stops = len(stops[agencyId])
+	}
+}
+
+// services finds the range of dates the feed has service on, and the days with the most trips
+func (stats *Stats) services(store *Store) error {
+	calendar, err := NewServiceCalendar(store)
+	if err != nil {
+		return err
+	}
+	if start, end, ok := calendar.DateRange(); ok {
+		stats.ServiceStart, stats.ServiceEnd = FormatDate(start), FormatDate(end)
+	}
+
+	tripsPerService := map[string]int{}
+	for _, trip := range store.Trip {
+		tripsPerService[trip.ServiceId]++
+	}
+	tripsPerDay := map[int]int{}
+	for serviceId, trips := range tripsPerService {
+		for _, day := range calendar.serviceDays(serviceId) {
+			tripsPerDay[day] += trips
+		}
+	}
+
+	days := make([]int, 0, len(tripsPerDay))
+	for day := range tripsPerDay {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if tripsPerDay[days[i]] != tripsPerDay[days[j]] {
+			return tripsPerDay[days[i]] > tripsPerDay[days[j]]
+		}
+		return days[i] < days[j]
+	})
+	for i := 0; i < len(days) && i < busiestDays; i++ {
+		stats.BusiestDays = append(stats.BusiestDays, DayStats{Date: FormatDate(dayDate(days[i])), Trips: tripsPerDay[days[i]]})
+	}
+	return nil
+}
+
+// tripLengths averages the length of trips in meters, measured along the part of their shape between the first and
+// the last stop, or in straight lines between their stops when they have no shape. shape_dist_traveled is only used to
+// find that part of the shape, as its unit differs between feeds.
+func (stats *Stats) tripLengths(store *Store, stopTimes map[string][]StopTime) {
+	_, shapes := shapesById(store.Shape)
+	stops := stopsById(store.Stop)
+
+	total := 0.0
+	for _, trip := range store.Trip {
+		tripStopTimes := stopTimes[trip.TripId]
+		if len(tripStopTimes) < 2 {
+			continue
+		}
+
+		length := 0.0
+		if points := shapes[trip.ShapeId]; len(points) > 1 {
+			from, to := trimShape(points, tripStopTimes[0], tripStopTimes[len(tripStopTimes)-1], stops)
+			for i := from + 1; i <= to; i++ {
+				length += distance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+			}
+		} else {
+			for i := 1; i < len(tripStopTimes); i++ {
+				previous, stop := stops[tripStopTimes[i-1].StopId], stops[tripStopTimes[i].StopId]
+				length += distance(previous.Lat, previous.Lon, stop.Lat, stop.Lon)
+			}
+		}
+		if length > 0 {
+			total += length
+			stats.TripsWithLength++
+		}
+	}
+	if stats.TripsWithLength > 0 {
+		stats.AverageTripLength = total / float64(stats.TripsWithLength)
+	}
+}
+
+// WriteText writes the statistics as aligned tables
+func (stats *Stats) WriteText(w io.Writer) error {
+	files := [][]string{{"File", "Rows", "Size"}}
+	for _, fileType := range FileTypes {
+		size := ""
+		if stats.Sizes != nil {
+			size = formatSize(stats.Sizes[fileType])
+		}
+		files = append(files, []string{fileType + ".txt", fmt.Sprint(stats.Rows[fileType]), size})
+	}
+
+	agencies := [][]string{{"Agency", "Name", "Routes", "Trips", "Stops"}}
+	for _, agency := range stats.Agencies {
+		agencies = append(agencies, []string{agency.Id, agency.Name, fmt.Sprint(agency.Routes), fmt.Sprint(agency.Trips), fmt.Sprint(agency.Stops)})
+	}
+
+	routeTypes := [][]string{{"Route type", "Trips"}}
+	for _, routeType := range sortedKeys(stats.TripsPerRouteType) {
+		routeTypes = append(routeTypes, []string{routeType, fmt.Sprint(stats.TripsPerRouteType[routeType])})
+	}
+
+	days := [][]string{{"Busiest day", "Trips"}}
+	for _, day := range stats.BusiestDays {
+		days = append(days, []string{day.Date, fmt.Sprint(day.Trips)})
+	}
+
+	for _, table := range [][][]string{files, agencies, routeTypes, days} {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, row := range table {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		fmt.Fprintln(tw)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "Service from %s to %s\n%d stops without service\nAverage trip length %.0f m over %d trips\n",
+		stats.ServiceStart, stats.ServiceEnd, len(stats.StopsWithoutService), stats.AverageTripLength, stats.TripsWithLength)
+	return err
+}
+
+func sortedKeys(values map[string]int) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatSize(bytes int64) string {
+	units := []string{"B", "kB", "MB", "GB"}
+	size, unit := float64(bytes), 0
+	for size >= 1000 && unit < len(units)-1 {
+		size /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
+
+func (stats *Stats) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
@@ -0,0 +1,66 @@
+package GTFS
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeStats(t *testing.T) {
+	store := testStore()
+	stats, err := ComputeStats(&store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Rows["stop_times"] != 2 || len(stats.Agencies) != 2 || stats.Agencies[0].Trips != 1 || stats.Agencies[0].Stops != 2 {
+		t.Errorf("unexpected counts %+v %+v", stats.Rows, stats.Agencies)
+	}
+	if stats.ServiceStart != "20261001" || stats.ServiceEnd != "20261231" {
+		t.Errorf("unexpected service range %s to %s", stats.ServiceStart, stats.ServiceEnd)
+	}
+	// weekends run both services
+	if len(stats.BusiestDays) != busiestDays || stats.BusiestDays[0].Trips != 2 || stats.BusiestDays[0].Date != "20261003" {
+		t.Errorf("unexpected busiest days %+v", stats.BusiestDays)
+	}
+	if stats.TripsPerRouteType["3"] != 1 || stats.TripsPerRouteType["0"] != 1 {
+		t.Errorf("unexpected trips per route type %+v", stats.TripsPerRouteType)
+	}
+}
+
+func TestTripLengths(t *testing.T) {
+	// a shape heading north in steps of 0.01 degrees, about 1112 meters, with shape_dist_traveled in kilometers
+	store := Store{
+		Shape: []Shape{
+			{Id: "north", PTSequence: 1, Lat: 52.37, Lon: 4.9, DistTraveled: 0},
+			{Id: "north", PTSequence: 2, Lat: 52.38, Lon: 4.9, DistTraveled: 1.112},
+			{Id: "north", PTSequence: 3, Lat: 52.39, Lon: 4.9, DistTraveled: 2.224},
+			{Id: "north", PTSequence: 4, Lat: 52.40, Lon: 4.9, DistTraveled: 3.336},
+		},
+		Stop: []Stop{
+			{Id: "a", Lat: 52.37, Lon: 4.9},
+			{Id: "b", Lat: 52.38, Lon: 4.9},
+			{Id: "c", Lat: 52.40, Lon: 4.9},
+		},
+		StopTime: []StopTime{
+			{TripId: "shaped", Sequence: 1, StopId: "b", ShapeDistTraveled: 1.112},
+			{TripId: "shaped", Sequence: 2, StopId: "c", ShapeDistTraveled: 3.336},
+			{TripId: "unshaped", Sequence: 1, StopId: "a"},
+			{TripId: "unshaped", Sequence: 2, StopId: "b"},
+			{TripId: "single", Sequence: 1, StopId: "a"},
+		},
+		Trip: []Trip{
+			{TripId: "shaped", ShapeId: "north"},
+			{TripId: "unshaped"},
+			{TripId: "single"},
+		},
+	}
+	stats, err := ComputeStats(&store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the shaped trip covers two steps of its shape and the unshaped trip one step between its stops, both in meters
+	if expected := 1.5 * 1112; stats.TripsWithLength != 2 || math.Abs(stats.AverageTripLength-expected) > 1 {
+		t.Errorf("got trip length %f over %d trips, expected %f over 2", stats.AverageTripLength, stats.TripsWithLength, expected)
+	}
+}
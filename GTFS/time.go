@@ -0,0 +1,76 @@
+package GTFS
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time is a time of a service day, in seconds since noon minus 12h of that day. Trips running past midnight
+// have times beyond 24:00:00, which still belong to the service day the trip started on. Times compare with the
+// usual operators.
+type Time int
+
+// NoTime is the value of a time that was left empty, like the arrival_time of a stop that isn't a timepoint
+const NoTime Time = -1
+
+func NewTime(hours, minutes, seconds int) Time {
+	return Time(hours*3600 + minutes*60 + seconds)
+}
+
+// ParseTime reads a time in the H:MM:SS or HH:MM:SS format, returning NoTime for an empty value
+func ParseTime(value string) (Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return NoTime, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || len(parts[1]) != 2 || len(parts[2]) != 2 {
+		return NoTime, ErrInvalidTime
+	}
+
+	values := [3]int{}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return NoTime, ErrInvalidTime
+		}
+		values[i] = number
+	}
+	if values[1] > 59 || values[2] > 59 {
+		return NoTime, ErrInvalidTime
+	}
+
+	return NewTime(values[0], values[1], values[2]), nil
+}
+
+func (t Time) IsSet() bool {
+	return t >= 0
+}
+
+// String formats the time as HH:MM:SS, or as an empty string for NoTime
+func (t Time) String() string {
+	if !t.IsSet() {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", t/3600, t/60%60, t%60)
+}
+
+func (t Time) Duration() time.Duration {
+	return time.Duration(t) * time.Second
+}
+
+func (t Time) Add(d time.Duration) Time {
+	return t + Time(d/time.Second)
+}
+
+// On returns the instant t refers to on the service day date, in location loc (the agency or stop timezone).
+// Times are counted from noon minus 12h, so on days with a daylight saving change they are shifted by an hour
+// compared to the wall clock, as the GTFS reference prescribes.
+func (t Time) On(date time.Time, loc *time.Location) time.Time {
+	year, month, day := date.Date()
+	noon := time.Date(year, month, day, 12, 0, 0, 0, loc)
+	return noon.Add(-12*time.Hour + t.Duration())
+}
@@ -0,0 +1,185 @@
+package GTFS
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrUnknownRoute = errors.New("unknown route")
+
+// kinds of TimetableCell
+const (
+	CellEmpty       = iota // the trip doesn't run on this part of the route
+	CellStop               // the trip stops here
+	CellPass               // the trip passes without stopping
+	CellDropOffOnly        // passengers can only get off
+	CellPickupOnly         // passengers can only get on
+)
+
+// Timetable lists the trips of a route on one service day, per direction
+type Timetable struct {
+	Route      Route
+	Date       time.Time
+	Directions []DirectionTimetable
+}
+
+// DirectionTimetable is a stop by trip matrix: Cells[i][j] tells when trip j calls at stop i
+type DirectionTimetable struct {
+	DirectionId int
+	Stops       []Stop
+	Trips       []Trip
+	Cells       [][]TimetableCell
+}
+
+type TimetableCell struct {
+	Kind int
+	Time Time // departure time, or arrival time at the last stop of a trip; NoTime when the stop is untimed
+}
+
+// Timetable builds the timetable of a route on the service day date. The rows of each direction are the stops of all
+// trip variants merged into one order, so that trips skipping stops or running a shorter route line up with the others.
+func (feed *Feed) Timetable(routeId string, date time.Time) (*Timetable, error) {
+	route := feed.Route(routeId)
+	if route == nil {
+		return nil, fmt.Errorf("%w %s", ErrUnknownRoute, routeId)
+	}
+	calendar, err := feed.ServiceCalendar()
+	if err != nil {
+		return nil, err
+	}
+
+	directions := map[int][]*Trip{}
+	for _, trip := range feed.RouteTrips(routeId) {
+		if calendar.IsActive(trip.ServiceId, date) && len(feed.TripStopTimes(trip.TripId)) > 0 {
+			directions[trip.DirectionId] = append(directions[trip.DirectionId], trip)
+		}
+	}
+	directionIds := make([]int, 0, len(directions))
+	for directionId := range directions {
+		directionIds = append(directionIds, directionId)
+	}
+	sort.Ints(directionIds)
+
+	timetable := &Timetable{Route: *route, Date: date}
+	for _, directionId := range directionIds {
+		timetable.Directions = append(timetable.Directions, feed.directionTimetable(directionId, directions[directionId]))
+	}
+	return timetable, nil
+}
+
+func (feed *Feed) directionTimetable(directionId int, trips []*Trip) DirectionTimetable {
+	sort.SliceStable(trips, func(i, j int) bool {
+		return firstDeparture(feed.TripStopTimes(trips[i].TripId)) < firstDeparture(feed.TripStopTimes(trips[j].TripId))
+	})
+
+	// the longest variant goes first, so the others are merged into the most complete order
+	patterns := make([][]StopTime, len(trips))
+	for i, trip := range trips {
+		patterns[i] = feed.TripStopTimes(trip.TripId)
+	}
+	byLength := make([]int, len(trips))
+	for i := range byLength {
+		byLength[i] = i
+	}
+	sort.SliceStable(byLength, func(a, b int) bool {
+		return len(patterns[byLength[a]]) > len(patterns[byLength[b]])
+	})
+	stopIds := make([]string, 0)
+	for _, i := range byLength {
+		stopIds = mergeStopOrder(stopIds, patterns[i])
+	}
+
+	direction := DirectionTimetable{DirectionId: directionId, Cells: make([][]TimetableCell, len(stopIds))}
+	for _, stopId := range stopIds {
+		stop := Stop{Id: stopId}
+		if found := feed.Stop(stopId); found != nil {
+			stop = *found
+		}
+		direction.Stops = append(direction.Stops, stop)
+	}
+	for i := range direction.Cells {
+		direction.Cells[i] = make([]TimetableCell, len(trips))
+	}
+
+	for j, trip := range trips {
+		direction.Trips = append(direction.Trips, *trip)
+		rows := matchRows(stopIds, patterns[j])
+		for k, stopTime := range patterns[j] {
+			last := k == len(patterns[j])-1
+			cell := TimetableCell{Kind: CellStop, Time: stopTime.DepartureTime}
+			if last || !cell.Time.IsSet() {
+				cell.Time = stopTime.ArrivalTime
+			}
+			if !cell.Time.IsSet() {
+				cell.Time = stopTime.DepartureTime
+			}
+			switch {
+			case stopTime.PickUpType == PickupNone && stopTime.DropOffType == PickupNone:
+				cell.Kind = CellPass
+			case stopTime.PickUpType == PickupNone && !last:
+				cell.Kind = CellDropOffOnly
+			case stopTime.DropOffType == PickupNone && k > 0:
+				cell.Kind = CellPickupOnly
+			}
+			direction.Cells[rows[k]][j] = cell
+		}
+
+		// stops between the first and last call of a trip that it doesn't call at are passed
+		for i := rows[0] + 1; i < rows[len(rows)-1]; i++ {
+			if direction.Cells[i][j].Kind == CellEmpty {
+				direction.Cells[i][j] = TimetableCell{Kind: CellPass, Time: NoTime}
+			}
+		}
+	}
+	return direction
+}
+
+func firstDeparture(stopTimes []StopTime) Time {
+	for _, stopTime := range stopTimes {
+		if stopTime.DepartureTime.IsSet() {
+			return stopTime.DepartureTime
+		}
+	}
+	return NoTime
+}
+
+// mergeStopOrder adds the stops of a trip to an order of stops, keeping both the existing order and the order of the
+// trip. Stops the order doesn't have yet are inserted after the stop the trip called at before.
+func mergeStopOrder(order []string, stopTimes []StopTime) []string {
+	position := -1 // row of the previous stop of the trip
+	for _, stopTime := range stopTimes {
+		found := -1
+		for i := position + 1; i < len(order); i++ {
+			if order[i] == stopTime.StopId {
+				found = i
+				break
+			}
+		}
+		if found == -1 {
+			found = position + 1
+			order = append(order, "")
+			copy(order[found+1:], order[found:])
+			order[found] = stopTime.StopId
+		}
+		position = found
+	}
+	return order
+}
+
+// matchRows finds the row of each stop time of a trip in a merged order of stops
+func matchRows(order []string, stopTimes []StopTime) []int {
+	rows := make([]int, len(stopTimes))
+	position := -1
+	for k, stopTime := range stopTimes {
+		for i := position + 1; i < len(order); i++ {
+			if order[i] == stopTime.StopId {
+				position = i
+				break
+			}
+		}
+		rows[k] = position
+	}
+	return rows
+}
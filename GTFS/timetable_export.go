@@ -0,0 +1,189 @@
+package GTFS
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// timetableLegend explains the marks used in timetable cells
+var timetableLegend = []struct{ Mark, Meaning string }{
+	{"|", "passes without stopping"},
+	{"a", "only for getting off"},
+	{"d", "only for getting on"},
+	{"•", "stops, time not published"},
+}
+
+// text shows a cell as a HH:MM time followed by a mark for restrictions, or a mark for passing trips
+func (cell TimetableCell) text() string {
+	switch cell.Kind {
+	case CellEmpty:
+		return ""
+	case CellPass:
+		return "|"
+	}
+
+	text := "•"
+	if cell.Time.IsSet() {
+		clock := cell.Time.String()
+		text = clock[:len(clock)-3]
+	}
+	switch cell.Kind {
+	case CellDropOffOnly:
+		text += " a"
+	case CellPickupOnly:
+		text += " d"
+	}
+	return text
+}
+
+// tripLabel names a trip in a column header, by its short name when it has one
+func tripLabel(trip Trip) string {
+	if trip.TripShortName != "" {
+		return trip.TripShortName
+	}
+	return trip.TripId
+}
+
+// headsign returns the headsign of a direction, taken from its first trip
+func (direction DirectionTimetable) headsign() string {
+	if len(direction.Trips) == 0 {
+		return ""
+	}
+	return direction.Trips[0].TripHeadsign
+}
+
+// WriteCSV writes each direction as a block of rows: a header naming the trips, a row of headsigns and a row per stop
+func (timetable *Timetable) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	for _, direction := range timetable.Directions {
+		directionId := fmt.Sprint(direction.DirectionId)
+
+		header := []string{"direction_id", "stop_id", "stop_name"}
+		headsigns := []string{directionId, "", "trip_headsign"}
+		for _, trip := range direction.Trips {
+			header = append(header, tripLabel(trip))
+			headsigns = append(headsigns, trip.TripHeadsign)
+		}
+		writer.Write(header)
+		writer.Write(headsigns)
+
+		for i, stop := range direction.Stops {
+			row := []string{directionId, stop.Id, stop.Name}
+			for _, cell := range direction.Cells[i] {
+				row = append(row, cell.text())
+			}
+			writer.Write(row)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteMarkdown writes a table per direction
+func (timetable *Timetable) WriteMarkdown(w io.Writer) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# %s %s\n\n%s\n", timetable.Route.RouteShortName, timetable.Route.RouteLongName, timetable.Date.Format("2006-01-02"))
+
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	for _, direction := range timetable.Directions {
+		fmt.Fprintf(b, "\n## Direction %d", direction.DirectionId)
+		if headsign := direction.headsign(); headsign != "" {
+			b.WriteString(": " + escape.Replace(headsign))
+		}
+		b.WriteString("\n\n| Stop |")
+		for _, trip := range direction.Trips {
+			fmt.Fprintf(b, " %s |", escape.Replace(tripLabel(trip)))
+		}
+		b.WriteString("\n| --- |" + strings.Repeat(" ---: |", len(direction.Trips)) + "\n")
+
+		for i, stop := range direction.Stops {
+			fmt.Fprintf(b, "| %s |", escape.Replace(stop.Name))
+			for _, cell := range direction.Cells[i] {
+				fmt.Fprintf(b, " %s |", escape.Replace(cell.text()))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+	for _, legend := range timetableLegend {
+		fmt.Fprintf(b, "- `%s` %s\n", legend.Mark, legend.Meaning)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlTimetable = template.Must(template.New("timetable").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Route.RouteShortName}} {{.Route.RouteLongName}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: .2em .5em; border-bottom: 1px solid #ddd; font-size: 90%; white-space: nowrap; }
+td.time { text-align: right; font-variant-numeric: tabular-nums; }
+thead th { text-align: right; }
+thead th:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Route.RouteShortName}} {{.Route.RouteLongName}}</h1>
+<p>{{.Date}}</p>
+{{range .Directions}}<h2>Direction {{.DirectionId}}{{if .Headsign}}: {{.Headsign}}{{end}}</h2>
+<table>
+<thead>
+<tr><th>Stop</th>{{range .Trips}}<th>{{.}}</th>{{end}}</tr>
+<tr><th></th>{{range .Headsigns}}<th>{{.}}</th>{{end}}</tr>
+</thead>
+<tbody>
+{{range .Rows}}<tr><th>{{.Stop}}</th>{{range .Cells}}<td class="time">{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}<ul>{{range .Legend}}<li><code>{{.Mark}}</code> {{.Meaning}}</li>{{end}}</ul>
+</body>
+</html>
+`))
+
+type htmlTimetableRow struct {
+	Stop  string
+	Cells []string
+}
+
+type htmlTimetableDirection struct {
+	DirectionId int
+	Headsign    string
+	Trips       []string
+	Headsigns   []string
+	Rows        []htmlTimetableRow
+}
+
+// WriteHTML writes a self-contained HTML page with a table per direction
+func (timetable *Timetable) WriteHTML(w io.Writer) error {
+	directions := make([]htmlTimetableDirection, 0, len(timetable.Directions))
+	for _, direction := range timetable.Directions {
+		page := htmlTimetableDirection{DirectionId: direction.DirectionId, Headsign: direction.headsign()}
+		for _, trip := range direction.Trips {
+			page.Trips = append(page.Trips, tripLabel(trip))
+			page.Headsigns = append(page.Headsigns, trip.TripHeadsign)
+		}
+		for i, stop := range direction.Stops {
+			row := htmlTimetableRow{Stop: stop.Name}
+			for _, cell := range direction.Cells[i] {
+				row.Cells = append(row.Cells, cell.text())
+			}
+			page.Rows = append(page.Rows, row)
+		}
+		directions = append(directions, page)
+	}
+
+	return htmlTimetable.Execute(w, struct {
+		Route      Route
+		Date       string
+		Directions []htmlTimetableDirection
+		Legend     interface{}
+	}{timetable.Route, timetable.Date.Format("2006-01-02"), directions, timetableLegend})
+}
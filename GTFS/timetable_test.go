@@ -0,0 +1,114 @@
+package GTFS
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimetable(t *testing.T) {
+	store := Store{
+		Calendar: []Calendar{{ServiceId: "weekdays", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, StartDate: "20261001", EndDate: "20261031"}},
+		Route:    []Route{{RouteId: "r", RouteShortName: "5"}},
+		Trip: []Trip{
+			{RouteId: "r", ServiceId: "weekdays", TripId: "express"},
+			{RouteId: "r", ServiceId: "weekdays", TripId: "local"},
+			{RouteId: "r", ServiceId: "weekdays", TripId: "branch"},
+			{RouteId: "r", ServiceId: "weekdays", TripId: "back", DirectionId: 1},
+		},
+	}
+	for _, stopId := range []string{"a", "b", "c", "d", "x"} {
+		store.Stop = append(store.Stop, Stop{Id: stopId, Name: strings.ToUpper(stopId)})
+	}
+	addTrip := func(tripId string, start Time, stopIds ...string) {
+		for i, stopId := range stopIds {
+			at := start + Time(i*300)
+			store.StopTime = append(store.StopTime, StopTime{TripId: tripId, Sequence: i + 1, StopId: stopId, ArrivalTime: at, DepartureTime: at})
+		}
+	}
+	addTrip("local", NewTime(8, 0, 0), "a", "b", "c", "d")
+	addTrip("express", NewTime(7, 0, 0), "a", "d")
+	addTrip("branch", NewTime(9, 0, 0), "a", "b", "x", "d")
+	addTrip("back", NewTime(10, 0, 0), "d", "a")
+	store.StopTime[3].PickUpType = PickupNone // passengers never board at the last stop, so this isn't marked
+	store.StopTime[8].PickUpType = PickupNone // branch only lets passengers off at x
+
+	timetable, err := NewFeed(&store).Timetable("r", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timetable.Directions) != 2 {
+		t.Fatalf("expected two directions, got %d", len(timetable.Directions))
+	}
+
+	out := &strings.Builder{}
+	if err := timetable.WriteCSV(out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `direction_id,stop_id,stop_name,express,local,branch
+0,,trip_headsign,,,
+0,a,A,07:00,08:00,09:00
+0,b,B,|,08:05,09:05
+0,x,X,|,|,09:10 a
+0,c,C,|,08:10,|
+0,d,D,07:05,08:15,09:15
+direction_id,stop_id,stop_name,back
+1,,trip_headsign,
+1,d,D,10:00
+1,a,A,10:05
+`
+	if out.String() != expected {
+		t.Errorf("unexpected timetable:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	if _, err := NewFeed(&store).Timetable("missing", timetable.Date); err == nil {
+		t.Error("expected an error for an unknown route")
+	}
+}
+
+func TestTimetableWriters(t *testing.T) {
+	timetable := &Timetable{
+		Route: Route{RouteId: "r", RouteShortName: "5", RouteLongName: "Centraal & Strand"},
+		Date:  time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		Directions: []DirectionTimetable{{
+			Stops: []Stop{{Id: "a", Name: "Centraal <1>"}, {Id: "b", Name: "Dam|Rak"}},
+			Trips: []Trip{{TripId: "t1", TripShortName: "1|2", TripHeadsign: "Zuid | Strand"}, {TripId: "t2"}},
+			Cells: [][]TimetableCell{
+				{{Kind: CellStop, Time: NewTime(8, 0, 0)}, {Kind: CellPickupOnly, Time: NewTime(8, 30, 0)}},
+				{{Kind: CellPass}, {Kind: CellDropOffOnly, Time: NoTime}},
+			},
+		}},
+	}
+
+	// pipes in names and cells would otherwise split table columns
+	out := &strings.Builder{}
+	if err := timetable.WriteMarkdown(out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# 5 Centraal & Strand\n\n2026-10-19\n" +
+		"\n## Direction 0: Zuid \\| Strand\n\n" +
+		"| Stop | 1\\|2 | t2 |\n" +
+		"| --- | ---: | ---: |\n" +
+		"| Centraal <1> | 08:00 | 08:30 d |\n" +
+		"| Dam\\|Rak | \\| | • a |\n" +
+		"\n- `|` passes without stopping\n- `a` only for getting off\n- `d` only for getting on\n- `•` stops, time not published\n"
+	if out.String() != expected {
+		t.Errorf("unexpected markdown:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	out.Reset()
+	if err := timetable.WriteHTML(out); err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{
+		"<title>5 Centraal &amp; Strand</title>",
+		"<h2>Direction 0: Zuid | Strand</h2>",
+		"<tr><th>Stop</th><th>1|2</th><th>t2</th></tr>",
+		"<tr><th>Centraal &lt;1&gt;</th><td class=\"time\">08:00</td><td class=\"time\">08:30 d</td></tr>",
+		"<tr><th>Dam|Rak</th><td class=\"time\">|</td><td class=\"time\">• a</td></tr>",
+	} {
+		if !strings.Contains(out.String(), part) {
+			t.Errorf("expected %q in html:\n%s", part, out)
+		}
+	}
+}
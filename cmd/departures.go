@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"github.com/Gerrist/gtfs-cli/util"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+var departuresInput string
+var departuresStop string
+var departuresAt string
+var departuresCount int
+var departuresStation bool
+var departuresFormat string
+
+func init() {
+	departuresCmd.PersistentFlags().StringVarP(&departuresInput, "input", "i", "", "Input GTFS directory or .zip file")
+	departuresCmd.PersistentFlags().StringVar(&departuresStop, "stop", "", "ID of the stop to list departures from")
+	departuresCmd.PersistentFlags().StringVar(&departuresAt, "at", "", "list departures from this moment on, in the timezone of the agency (example: 2026-10-18T08:00, default now)")
+	departuresCmd.PersistentFlags().IntVarP(&departuresCount, "count", "n", 10, "number of departures to list")
+	departuresCmd.PersistentFlags().BoolVar(&departuresStation, "station", false, "include departures from all platforms of the parent station")
+	departuresCmd.PersistentFlags().StringVar(&departuresFormat, "format", "text", "Output format: text or json")
+	rootCmd.AddCommand(departuresCmd)
+}
+
+// descriptions of StopTime.PickUpType values other than regular pickup
+var pickupRestrictions = map[int]string{
+	GTFS.PickupNone:        "no boarding",
+	GTFS.PickupPhoneAgency: "phone agency to board",
+	GTFS.PickupAskDriver:   "ask driver to board",
+}
+
+var departuresCmd = &cobra.Command{
+	Use:   "departures",
+	Short: "List the next departures from a stop",
+	Long:  `List the next departures from a stop, or from all platforms of a station, with their route, headsign and boarding restrictions.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if departuresInput == "" {
+			log.Panicln("input flag can't be empty (example: -input=gtfs-nl.zip)")
+		}
+		if departuresStop == "" {
+			log.Panicln("stop flag can't be empty (example: -stop=stoparea:123)")
+		}
+		if departuresCount < 1 {
+			log.Panicln("count flag should be at least 1 (example: -count=10)")
+		}
+		if departuresFormat != "text" && departuresFormat != "json" {
+			log.Panicln("format flag should be text or json")
+		}
+		if !util.DirectoryExists(departuresInput) && !util.FileExists(departuresInput) {
+			log.Panicln("Input directory or zip file does not exists")
+		}
+
+		log.Println("[Import]", "Importing GTFS from", departuresInput)
+		gtfs := GTFS.Store{}
+		if err := gtfs.Load(departuresInput); err != nil {
+			log.Fatalln("[Import]", err)
+		}
+		feed := GTFS.NewFeed(&gtfs)
+
+		at := parseMomentFlag("at", departuresAt, feedLocation(&gtfs))
+		departures, err := feed.Departures(departuresStop, at, departuresCount, departuresStation)
+		if err != nil {
+			log.Fatalln("[Departures]", err)
+		}
+
+		if departuresFormat == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(departures)
+		} else {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, departure := range departures {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", departure.Time.Format("2006-01-02 15:04"), departure.RouteShortName, departure.Headsign,
+					departure.PlatformCode, pickupRestrictions[departure.PickupType])
+			}
+			err = tw.Flush()
+		}
+		if err != nil {
+			log.Fatalln("[Export]", err)
+		}
+	},
+}
+
+// feedLocation returns the timezone of the first agency of a feed, in which times given on the command line are read
+func feedLocation(store *GTFS.Store) *time.Location {
+	if len(store.Agency) > 0 {
+		if location, err := time.LoadLocation(store.Agency[0].Timezone); err == nil && store.Agency[0].Timezone != "" {
+			return location
+		}
+	}
+	return time.Local
+}
+
+// parseMomentFlag reads a date and time given as YYYY-MM-DDTHH:MM in location, defaulting to now
+func parseMomentFlag(flag, value string, location *time.Location) time.Time {
+	if value == "" {
+		return time.Now().In(location)
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+		if moment, err := time.ParseInLocation(layout, value, location); err == nil {
+			return moment
+		}
+	}
+	if moment, err := time.Parse(time.RFC3339, value); err == nil {
+		return moment.In(location)
+	}
+	log.Panicln(flag, "flag is not a date and time (example: -"+flag+"=2026-10-18T08:00)")
+	return time.Time{}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"github.com/Gerrist/gtfs-cli/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+)
+
+var diffMoveThreshold float64
+var diffFormat string
+var diffOutput string
+
+func init() {
+	diffCmd.PersistentFlags().Float64Var(&diffMoveThreshold, "move-threshold", 25, "meters a stop has to move before it is reported as moved")
+	diffCmd.PersistentFlags().StringVar(&diffFormat, "format", "text", "Output format: text or json")
+	diffCmd.PersistentFlags().StringVarP(&diffOutput, "output", "o", "", "File where the changes are stored (default standard output)")
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Show what changed between two versions of a GTFS feed",
+	Long: `Show which agencies, routes, stops, trips and services were added, removed or modified between two versions of a
+GTFS feed, given as directories or .zip files.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if diffFormat != "text" && diffFormat != "json" {
+			log.Panicln("format flag should be text or json")
+		}
+
+		feeds := [2]GTFS.Store{}
+		for i, input := range args {
+			if !util.DirectoryExists(input) && !util.FileExists(input) {
+				log.Panicln("Input directory or zip file", input, "does not exists")
+			}
+			log.Println("[Import]", "Importing GTFS from", input)
+			if err := feeds[i].Load(input); err != nil {
+				log.Fatalln("[Import]", err)
+			}
+		}
+
+		diff, err := GTFS.Diff(&feeds[0], &feeds[1], GTFS.DiffOptions{MoveThreshold: diffMoveThreshold})
+		if err != nil {
+			log.Fatalln("[Diff]", err)
+		}
+
+		var output io.Writer = os.Stdout
+		if diffOutput != "" {
+			file, err := os.Create(diffOutput)
+			if err != nil {
+				log.Fatalln("[Export]", err)
+			}
+			defer file.Close()
+			output = file
+		}
+
+		if diffFormat == "json" {
+			err = diff.WriteJSON(output)
+		} else {
+			err = diff.WriteText(output)
+		}
+		if err != nil {
+			log.Fatalln("[Export]", err)
+		}
+	},
+}
@@ -5,123 +5,215 @@ import (
 	"github.com/Gerrist/gtfs-cli/util"
 	"github.com/spf13/cobra"
 	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
 )
 
-var filterAgency string
+var filterAgencies []string
+var filterAgencyRegex string
+var filterRoutes []string
+var filterRouteTypes []string
+var filterRouteShortNames []string
+var filterBoundingBox string
+var filterPolygon string
+var filterClip bool
+var filterFrom string
+var filterTo string
+var filterDays int
 var inputDir string
 var outputDir string
 
 func init() {
-	versionCmd.PersistentFlags().StringVarP(&filterAgency, "agency", "a", "", "agency to extract data from")
-	versionCmd.PersistentFlags().StringVarP(&inputDir, "input", "i", "", "Input GTFS directory")
-	versionCmd.PersistentFlags().StringVarP(&outputDir, "output", "o", "", "Directory where output is stored")
-	rootCmd.AddCommand(versionCmd)
+	extractCmd.PersistentFlags().StringSliceVarP(&filterAgencies, "agency", "a", nil, "agencies to extract data from, as IDs or glob patterns (repeat or separate with commas)")
+	extractCmd.PersistentFlags().StringVar(&filterAgencyRegex, "agency-regex", "", "regular expression matching IDs of agencies to extract data from")
+	extractCmd.PersistentFlags().StringSliceVarP(&filterRoutes, "route", "r", nil, "routes to extract, as IDs or glob patterns")
+	extractCmd.PersistentFlags().StringSliceVar(&filterRouteTypes, "route-type", nil, "route types to extract (example: 0 for trams)")
+	extractCmd.PersistentFlags().StringSliceVar(&filterRouteShortNames, "route-short-name", nil, "route short names to extract, as names, glob patterns or numeric ranges (example: 1-12)")
+	extractCmd.PersistentFlags().StringVar(&filterBoundingBox, "bbox", "", "only keep trips stopping inside this area, given as minLon,minLat,maxLon,maxLat")
+	extractCmd.PersistentFlags().StringVar(&filterPolygon, "polygon", "", "only keep trips stopping inside the polygons of this GeoJSON file")
+	extractCmd.PersistentFlags().BoolVar(&filterClip, "clip", false, "cut trips down to their part inside the bbox or polygon area")
+	extractCmd.PersistentFlags().StringVar(&filterFrom, "from", "", "only keep service from this date on (example: 2026-10-18)")
+	extractCmd.PersistentFlags().StringVar(&filterTo, "to", "", "only keep service up to and including this date (example: 2026-10-31)")
+	extractCmd.PersistentFlags().IntVar(&filterDays, "days", 0, "only keep service for this many days, starting at --from or today")
+	extractCmd.PersistentFlags().StringVarP(&inputDir, "input", "i", "", "Input GTFS directory or .zip file")
+	extractCmd.PersistentFlags().StringVarP(&outputDir, "output", "o", "", "Directory or .zip file where output is stored")
+	rootCmd.AddCommand(extractCmd)
 }
 
-var versionCmd = &cobra.Command{
+var extractCmd = &cobra.Command{
 	Use:   "extract",
-	Short: "Extract agency from GTFS",
-	Long:  `Extract agency from GTFS`,
+	Short: "Extract agencies, routes, areas or dates from GTFS",
+	Long: `Extract agencies, routes, areas or dates from GTFS, together with the trips, calendars, stops and shapes they use.
+When several kinds of selectors are given, only trips matching all of them are extracted.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if filterAgency == "" {
-			log.Panicln("agency flag can't be empty (example: -agency=CXX)")
+		filter, filterDescription := extractFilter()
+		if filterDescription == "" {
+			log.Panicln("agency, route, area or date flags can't be empty (example: -agency=CXX,QBUZZ, -route-type=0, -bbox=4.7,52.3,5.0,52.4 or -days=7)")
 		}
 		if inputDir == "" {
-			log.Panicln("input flag can't be empty (example: -input=gtfs-data)")
+			log.Panicln("input flag can't be empty (example: -input=gtfs-nl.zip)")
 		}
 		if outputDir == "" {
-			log.Panicln("output flag can't be empty (example: -input=niag-gtfs)")
+			log.Panicln("output flag can't be empty (example: -output=niag-gtfs.zip)")
 		}
 
-		if !util.DirectoryExists(inputDir) {
-			log.Panicln("Input directory does not exists")
+		if !util.DirectoryExists(inputDir) && !util.FileExists(inputDir) {
+			log.Panicln("Input directory or zip file does not exists")
 		} else {
 			gtfs := GTFS.Store{}
 
-			log.Println("[Import]", "Importing agency.txt")
-			gtfs.ReadFile("agency", "./" + inputDir + "/agency.txt")
-
-			log.Println("[Import]", "Importing calendar_dates.txt")
-			gtfs.ReadFile("calendar_dates", "./" + inputDir + "/calendar_dates.txt")
-
-			log.Println("[Import]", "Importing routes.txt")
-			gtfs.ReadFile("routes", "./" + inputDir + "/routes.txt")
-
-			log.Println("[Import]", "Importing shapes.txt")
-			gtfs.ReadFile("shapes", "./" + inputDir + "/shapes.txt")
-
-			log.Println("[Import]", "Importing stop_times.txt")
-			gtfs.ReadFile("stop_times", "./" + inputDir + "/stop_times.txt")
-
-			log.Println("[Import]", "Importing stops.txt")
-			gtfs.ReadFile("stops", "./" + inputDir + "/stops.txt")
-
-			log.Println("[Import]", "Importing transfers.txt")
-			gtfs.ReadFile("transfers", "./" + inputDir + "/transfers.txt")
-
-			log.Println("[Import]", "Importing trips.txt")
-			gtfs.ReadFile("trips", "./" + inputDir + "/trips.txt")
-
-			log.Println("[Filter]", "Filtering GTFS with", filterAgency, "data")
-
-			newGtfs := GTFS.Store{}
-
-			for _, agency := range gtfs.Agency {
-				if agency.Id == filterAgency {
-					newGtfs.Agency = append(newGtfs.Agency, agency)
-				}
+			reader, err := GTFS.OpenReader(inputDir)
+			if err != nil {
+				log.Fatalln("[Import]", err)
 			}
+			defer reader.Close()
 
-			routeIds := make([]string, 0)
-			for _, route := range gtfs.Route {
-				if route.AgencyId == filterAgency {
-					newGtfs.Route = append(newGtfs.Route, route)
-					routeIds = append(routeIds, route.RouteId)
-				}
+			// stop_times.txt is by far the largest file, so it is filtered while reading instead of loaded up front
+			log.Println("[Import]", "Importing GTFS from", inputDir)
+			if err := gtfs.LoadFrom(reader, "agency", "calendar", "calendar_dates", "routes", "shapes", "stops", "transfers", "trips"); err != nil {
+				log.Fatalln("[Import]", err)
 			}
 
-			tripIds := make([]string, 0)
-			shapeIds := make([]string, 0)
-			serviceIds := make([]string, 0)
-			for _, trip := range gtfs.Trip {
-				if util.IndexOf(trip.RouteId, routeIds) > -1 {
-					newGtfs.Trip = append(newGtfs.Trip, trip)
-					tripIds = append(tripIds, trip.TripId)
-					serviceIds = append(serviceIds, trip.ServiceId)
-					shapeIds = append(shapeIds, trip.ShapeId)
-				}
+			log.Println("[Filter]", "Filtering GTFS with", filterDescription, "data")
+			extractor, err := GTFS.NewExtractor(&gtfs, filter)
+			if err != nil {
+				log.Fatalln("[Filter]", err)
 			}
 
-			for _, calendarDate := range gtfs.CalendarDates {
-				if util.IndexOf(calendarDate.ServiceId, serviceIds) > -1 {
-					newGtfs.CalendarDates = append(newGtfs.CalendarDates, calendarDate)
+			if extractor.NeedsScan() {
+				log.Println("[Filter]", "Scanning stop_times.txt for trips stopping in the area")
+				err = reader.EachStopTime(func(stopTime GTFS.StopTime) error {
+					extractor.Scan(stopTime)
+					return nil
+				})
+				if err != nil {
+					log.Fatalln("[Import]", err)
 				}
 			}
 
-			stopIds := make([]string, 0)
-			for _, stopTime := range gtfs.StopTime {
-				if util.IndexOf(stopTime.TripId, tripIds) > -1 {
-					newGtfs.StopTime = append(newGtfs.StopTime, stopTime)
-					stopIds = append(stopIds, stopTime.StopId)
-				}
+			log.Println("[Filter]", "Streaming stop_times.txt")
+			err = reader.EachStopTime(func(stopTime GTFS.StopTime) error {
+				extractor.AddStopTime(stopTime)
+				return nil
+			})
+			if err != nil {
+				log.Fatalln("[Import]", err)
 			}
 
-			for _, stop := range gtfs.Stop {
-				if util.IndexOf(stop.Id, stopIds) > -1 {
-					newGtfs.Stop = append(newGtfs.Stop, stop)
-				}
-			}
+			newGtfs := extractor.Result()
 
-			for _, shape := range gtfs.Shape {
-				if util.IndexOf(shape.Id, shapeIds) > -1 {
-					newGtfs.Shape = append(newGtfs.Shape, shape)
-				}
+			log.Println("[Export]", "Exporting new GTFS with", filterDescription, "data to", outputDir)
+
+			if err := newGtfs.Export(outputDir); err != nil {
+				log.Fatalln("[Export]", err)
 			}
+		}
 
-			log.Println("[Export]", "Exporting new GTFS with", filterAgency, "data to", outputDir)
+	},
+}
 
-			newGtfs.Export(outputDir)
+// extractFilter builds the filter from the selector flags, together with a description of it for the log
+func extractFilter() (GTFS.Filter, string) {
+	filter := GTFS.Filter{
+		Agencies:        filterAgencies,
+		Routes:          filterRoutes,
+		RouteTypes:      filterRouteTypes,
+		RouteShortNames: filterRouteShortNames,
+	}
+
+	description := make([]string, 0)
+	if len(filterAgencies) > 0 {
+		description = append(description, "agency "+strings.Join(filterAgencies, ","))
+	}
+	if filterAgencyRegex != "" {
+		pattern, err := regexp.Compile(filterAgencyRegex)
+		if err != nil {
+			log.Panicln("agency-regex flag is not a valid regular expression:", err)
+		}
+		filter.AgencyPattern = pattern
+		description = append(description, "agency /"+filterAgencyRegex+"/")
+	}
+	if len(filterRoutes) > 0 {
+		description = append(description, "route "+strings.Join(filterRoutes, ","))
+	}
+	if len(filterRouteTypes) > 0 {
+		description = append(description, "route type "+strings.Join(filterRouteTypes, ","))
+	}
+	if len(filterRouteShortNames) > 0 {
+		description = append(description, "route short name "+strings.Join(filterRouteShortNames, ","))
+	}
+
+	if filterBoundingBox != "" && filterPolygon != "" {
+		log.Panicln("bbox and polygon flags can't be combined")
+	}
+	if filterBoundingBox != "" {
+		box, err := GTFS.ParseBoundingBox(filterBoundingBox)
+		if err != nil {
+			log.Panicln("bbox flag is invalid:", err)
+		}
+		filter.Area = box
+		description = append(description, "area "+filterBoundingBox)
+	}
+	if filterPolygon != "" {
+		polygonFile, err := os.Open(filterPolygon)
+		if err != nil {
+			log.Panicln("polygon flag is invalid:", err)
+		}
+		polygons, err := GTFS.ReadGeoJSON(polygonFile)
+		polygonFile.Close()
+		if err != nil {
+			log.Panicln("polygon flag is invalid:", err)
 		}
+		filter.Area = polygons
+		description = append(description, "area "+filterPolygon)
+	}
+	if filterFrom != "" {
+		filter.From = parseDateFlag("from", filterFrom)
+	}
+	if filterTo != "" {
+		filter.To = parseDateFlag("to", filterTo)
+	}
+	if filterDays > 0 {
+		if filterTo != "" {
+			log.Panicln("days and to flags can't be combined")
+		}
+		if filter.From.IsZero() {
+			filter.From = time.Now()
+		}
+		filter.To = filter.From.AddDate(0, 0, filterDays-1)
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		description = append(description, "dates "+formatDateFlag(filter.From)+" to "+formatDateFlag(filter.To))
+	}
+
+	if filterClip {
+		if filter.Area == nil {
+			log.Panicln("clip flag needs a bbox or polygon area")
+		}
+		filter.Clip = true
+		description = append(description, "clipped")
+	}
 
-	},
+	return filter, strings.Join(description, ", ")
+}
+
+// parseDateFlag reads a date given as YYYY-MM-DD or as a GTFS YYYYMMDD date
+func parseDateFlag(flag, value string) time.Time {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		date, err = GTFS.ParseDate(value)
+	}
+	if err != nil {
+		log.Panicln(flag, "flag is not a date (example: -"+flag+"=2026-10-18)")
+	}
+	return date
+}
+
+func formatDateFlag(date time.Time) string {
+	if date.IsZero() {
+		return "..."
+	}
+	return date.Format("2006-01-02")
 }
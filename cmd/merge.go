@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"github.com/Gerrist/gtfs-cli/util"
+	"github.com/spf13/cobra"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+var mergeInputs []string
+var mergePrefixes []string
+var mergeNoDedupe bool
+var mergeOutput string
+
+func init() {
+	mergeCmd.PersistentFlags().StringSliceVarP(&mergeInputs, "input", "i", nil, "Input GTFS directories or .zip files, in order of precedence (repeat or separate with commas)")
+	mergeCmd.PersistentFlags().StringSliceVar(&mergePrefixes, "prefix", nil, "prefixes for colliding IDs, one per input (default the input file name followed by _)")
+	mergeCmd.PersistentFlags().BoolVar(&mergeNoDedupe, "no-dedupe", false, "rename colliding IDs even when both rows are identical")
+	mergeCmd.PersistentFlags().StringVarP(&mergeOutput, "output", "o", "", "Directory or .zip file where output is stored")
+	rootCmd.AddCommand(mergeCmd)
+}
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Merge several GTFS feeds into one",
+	Long: `Merge several GTFS feeds into one. IDs that are already used by an earlier input are merged when both rows are
+identical, and otherwise renamed with the prefix of the input they come from. Stops identical to a stop of an earlier
+input, at the same coordinates, are merged into it whatever their ID.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(mergeInputs) < 2 {
+			log.Panicln("input flag needs at least two feeds (example: -input=arriva.zip,qbuzz.zip)")
+		}
+		if mergeOutput == "" {
+			log.Panicln("output flag can't be empty (example: -output=merged-gtfs.zip)")
+		}
+		if len(mergePrefixes) > 0 && len(mergePrefixes) != len(mergeInputs) {
+			log.Panicln("prefix flag needs one prefix per input")
+		}
+		for _, prefix := range mergePrefixes {
+			if prefix == "" {
+				log.Panicln("prefix flag can't contain an empty prefix (example: -prefix=arriva_,qbuzz_)")
+			}
+		}
+
+		gtfs := GTFS.Store{}
+		for i, input := range mergeInputs {
+			if !util.DirectoryExists(input) && !util.FileExists(input) {
+				log.Panicln("Input directory or zip file", input, "does not exists")
+			}
+
+			log.Println("[Import]", "Importing GTFS from", input)
+			feed := GTFS.Store{}
+			if err := feed.Load(input); err != nil {
+				log.Fatalln("[Import]", err)
+			}
+
+			prefix := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)) + "_"
+			if len(mergePrefixes) > 0 {
+				prefix = mergePrefixes[i]
+			}
+
+			stats := gtfs.Merge(&feed, GTFS.MergeOptions{Prefix: prefix, Dedupe: !mergeNoDedupe})
+			for _, fileType := range []string{"agency", "stops", "routes", "shapes", "service", "trips"} {
+				if stats.Renamed[fileType] > 0 || stats.Merged[fileType] > 0 {
+					log.Println("[Merge]", input, fileType+":", stats.Renamed[fileType], "colliding IDs renamed,", stats.Merged[fileType], "identical rows merged")
+				}
+			}
+		}
+
+		log.Println("[Export]", "Exporting merged GTFS to", mergeOutput)
+		if err := gtfs.Export(mergeOutput); err != nil {
+			log.Fatalln("[Export]", err)
+		}
+	},
+}
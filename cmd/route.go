@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"github.com/Gerrist/gtfs-cli/util"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var routeInput string
+var routeFrom string
+var routeTo string
+var routeDepart string
+var routeMaxTransfers int
+var routeChangeTime time.Duration
+var routeFormat string
+
+func init() {
+	routeCmd.PersistentFlags().StringVarP(&routeInput, "input", "i", "", "Input GTFS directory or .zip file")
+	routeCmd.PersistentFlags().StringVar(&routeFrom, "from", "", "ID of the stop or station to travel from")
+	routeCmd.PersistentFlags().StringVar(&routeTo, "to", "", "ID of the stop or station to travel to")
+	routeCmd.PersistentFlags().StringVar(&routeDepart, "depart", "", "leave at or after this moment, in the timezone of the agency (example: 2026-10-18T08:00, default now)")
+	routeCmd.PersistentFlags().IntVar(&routeMaxTransfers, "max-transfers", 5, "maximum number of transfers")
+	routeCmd.PersistentFlags().DurationVar(&routeChangeTime, "change-time", 2*time.Minute, "time needed to change trips at a stop, or between platforms of a station, that transfers.txt doesn't give a time for")
+	routeCmd.PersistentFlags().StringVar(&routeFormat, "format", "text", "Output format: text or json")
+	rootCmd.AddCommand(routeCmd)
+}
+
+var routeCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Plan journeys between two stops",
+	Long: `Plan journeys between two stops, leaving at or after a moment. Lists the fastest journey for each number of
+transfers, as long as it arrives earlier than journeys with fewer transfers.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if routeInput == "" {
+			log.Panicln("input flag can't be empty (example: -input=gtfs-nl.zip)")
+		}
+		if routeFrom == "" {
+			log.Panicln("from flag can't be empty (example: -from=stoparea:123)")
+		}
+		if routeTo == "" {
+			log.Panicln("to flag can't be empty (example: -to=stoparea:456)")
+		}
+		if routeMaxTransfers < 0 {
+			log.Panicln("max-transfers flag can't be negative")
+		}
+		if routeFormat != "text" && routeFormat != "json" {
+			log.Panicln("format flag should be text or json")
+		}
+		if !util.DirectoryExists(routeInput) && !util.FileExists(routeInput) {
+			log.Panicln("Input directory or zip file does not exists")
+		}
+
+		log.Println("[Import]", "Importing GTFS from", routeInput)
+		gtfs := GTFS.Store{}
+		if err := gtfs.Load(routeInput); err != nil {
+			log.Fatalln("[Import]", err)
+		}
+		feed := GTFS.NewFeed(&gtfs)
+
+		depart := parseMomentFlag("depart", routeDepart, feedLocation(&gtfs))
+		journeys, err := feed.Journeys(routeFrom, routeTo, depart, GTFS.JourneyOptions{MaxTransfers: routeMaxTransfers, ChangeTime: routeChangeTime})
+		if err != nil {
+			log.Fatalln("[Route]", err)
+		}
+
+		if routeFormat == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(journeys)
+		} else {
+			err = writeJourneys(feed, journeys)
+		}
+		if err != nil {
+			log.Fatalln("[Export]", err)
+		}
+	},
+}
+
+// writeJourneys lists the legs of each journey under a line with its departure, arrival and number of transfers
+func writeJourneys(feed *GTFS.Feed, journeys []GTFS.Journey) error {
+	if len(journeys) == 0 {
+		_, err := fmt.Println("No journeys found")
+		return err
+	}
+
+	stopName := func(stopId string) string {
+		if stop := feed.Stop(stopId); stop != nil && stop.Name != "" {
+			return stop.Name
+		}
+		return stopId
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, journey := range journeys {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s - %s, %s, %d transfer(s)\n", journey.Departure.Format("2006-01-02 15:04"), journey.Arrival.Format("15:04"),
+			journey.Arrival.Sub(journey.Departure), journey.Transfers)
+		for _, leg := range journey.Legs {
+			service := "walk"
+			if leg.Mode == GTFS.LegTransit {
+				service = strings.TrimSpace(leg.RouteShortName + " " + leg.Headsign)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", leg.Departure.Format("15:04"), stopName(leg.FromStopId), leg.Arrival.Format("15:04"),
+				stopName(leg.ToStopId), service)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"github.com/Gerrist/gtfs-cli/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+)
+
+var statsInput string
+var statsFormat string
+var statsOutput string
+
+func init() {
+	statsCmd.PersistentFlags().StringVarP(&statsInput, "input", "i", "", "Input GTFS directory or .zip file")
+	statsCmd.PersistentFlags().StringVar(&statsFormat, "format", "text", "Output format: text or json")
+	statsCmd.PersistentFlags().StringVarP(&statsOutput, "output", "o", "", "File where the statistics are stored (default standard output)")
+	rootCmd.AddCommand(statsCmd)
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarise the contents of GTFS",
+	Long: `Summarise the contents of GTFS: rows and sizes of each file, routes, trips and stops per agency, the service date
+range and busiest days, trips per route type, stops without service and the average trip length.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if statsInput == "" {
+			log.Panicln("input flag can't be empty (example: -input=gtfs-nl.zip)")
+		}
+		if statsFormat != "text" && statsFormat != "json" {
+			log.Panicln("format flag should be text or json")
+		}
+		if !util.DirectoryExists(statsInput) && !util.FileExists(statsInput) {
+			log.Panicln("Input directory or zip file does not exists")
+		}
+
+		reader, err := GTFS.OpenReader(statsInput)
+		if err != nil {
+			log.Fatalln("[Import]", err)
+		}
+		defer reader.Close()
+
+		log.Println("[Import]", "Importing GTFS from", statsInput)
+		gtfs := GTFS.Store{}
+		if err := gtfs.LoadFrom(reader, GTFS.FileTypes...); err != nil {
+			log.Fatalln("[Import]", err)
+		}
+
+		stats, err := GTFS.ComputeStats(&gtfs)
+		if err != nil {
+			log.Fatalln("[Stats]", err)
+		}
+		stats.Sizes = map[string]int64{}
+		for _, fileType := range GTFS.FileTypes {
+			stats.Sizes[fileType] = reader.FileSize(fileType)
+		}
+
+		var output io.Writer = os.Stdout
+		if statsOutput != "" {
+			file, err := os.Create(statsOutput)
+			if err != nil {
+				log.Fatalln("[Export]", err)
+			}
+			defer file.Close()
+			output = file
+		}
+
+		if statsFormat == "json" {
+			err = stats.WriteJSON(output)
+		} else {
+			err = stats.WriteText(output)
+		}
+		if err != nil {
+			log.Fatalln("[Export]", err)
+		}
+	},
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"github.com/Gerrist/gtfs-cli/util"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+var timetableInput string
+var timetableRoute string
+var timetableDate string
+var timetableFormat string
+var timetableOutput string
+
+func init() {
+	timetableCmd.PersistentFlags().StringVarP(&timetableInput, "input", "i", "", "Input GTFS directory or .zip file")
+	timetableCmd.PersistentFlags().StringVarP(&timetableRoute, "route", "r", "", "ID of the route to build the timetable of")
+	timetableCmd.PersistentFlags().StringVar(&timetableDate, "date", "", "service day of the timetable (example: 2026-10-18, default today)")
+	timetableCmd.PersistentFlags().StringVar(&timetableFormat, "format", "markdown", "Output format: csv, html or markdown")
+	timetableCmd.PersistentFlags().StringVarP(&timetableOutput, "output", "o", "", "File where the timetable is stored (default standard output)")
+	rootCmd.AddCommand(timetableCmd)
+}
+
+var timetableCmd = &cobra.Command{
+	Use:   "timetable",
+	Short: "Build the timetable of a route on a day",
+	Long: `Build the timetable of a route on a day: a table per direction with a row per stop and a column per trip, in the
+order of the stops of all trip variants of the route.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if timetableInput == "" {
+			log.Panicln("input flag can't be empty (example: -input=gtfs-nl.zip)")
+		}
+		if timetableRoute == "" {
+			log.Panicln("route flag can't be empty (example: -route=12345)")
+		}
+		if timetableFormat != "csv" && timetableFormat != "html" && timetableFormat != "markdown" {
+			log.Panicln("format flag should be csv, html or markdown")
+		}
+		if !util.DirectoryExists(timetableInput) && !util.FileExists(timetableInput) {
+			log.Panicln("Input directory or zip file does not exists")
+		}
+		date := time.Now()
+		if timetableDate != "" {
+			date = parseDateFlag("date", timetableDate)
+		}
+
+		log.Println("[Import]", "Importing GTFS from", timetableInput)
+		gtfs := GTFS.Store{}
+		if err := gtfs.Load(timetableInput); err != nil {
+			log.Fatalln("[Import]", err)
+		}
+
+		timetable, err := GTFS.NewFeed(&gtfs).Timetable(timetableRoute, date)
+		if err != nil {
+			log.Fatalln("[Timetable]", err)
+		}
+
+		var output io.Writer = os.Stdout
+		if timetableOutput != "" {
+			file, err := os.Create(timetableOutput)
+			if err != nil {
+				log.Fatalln("[Export]", err)
+			}
+			defer file.Close()
+			output = file
+		}
+
+		switch timetableFormat {
+		case "csv":
+			err = timetable.WriteCSV(output)
+		case "html":
+			err = timetable.WriteHTML(output)
+		default:
+			err = timetable.WriteMarkdown(output)
+		}
+		if err != nil {
+			log.Fatalln("[Export]", err)
+		}
+	},
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"github.com/Gerrist/gtfs-cli/util"
+	"github.com/Gerrist/gtfs-cli/validation"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+)
+
+var validateInput string
+var validateOutput string
+var validateFormat string
+var validateFailOn string
+
+func init() {
+	validateCmd.PersistentFlags().StringVarP(&validateInput, "input", "i", "", "Input GTFS directory or .zip file")
+	validateCmd.PersistentFlags().StringVarP(&validateOutput, "output", "o", "", "File where the report is stored (default standard output)")
+	validateCmd.PersistentFlags().StringVar(&validateFormat, "format", "text", "Report format: text, json or html")
+	validateCmd.PersistentFlags().StringVar(&validateFailOn, "fail-on", "error", "Exit with status 1 when issues of this severity or worse are found: error, warning, info or none")
+	rootCmd.AddCommand(validateCmd)
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check GTFS against the specification",
+	Long: `Check GTFS against the specification: required files and fields, references between files, value ranges,
+duplicate IDs and the order of stop times.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if validateInput == "" {
+			log.Panicln("input flag can't be empty (example: -input=gtfs-nl.zip)")
+		}
+		if validateFormat != "text" && validateFormat != "json" && validateFormat != "html" {
+			log.Panicln("format flag should be text, json or html")
+		}
+		failOn, failOnNone := validation.Severity(0), validateFailOn == "none"
+		if !failOnNone {
+			var err error
+			if failOn, err = validation.ParseSeverity(validateFailOn); err != nil {
+				log.Panicln("fail-on flag is invalid:", err)
+			}
+		}
+		if !util.DirectoryExists(validateInput) && !util.FileExists(validateInput) {
+			log.Panicln("Input directory or zip file does not exists")
+		}
+
+		log.Println("[Import]", "Validating GTFS from", validateInput)
+		report, err := validation.Validate(validateInput)
+		if err != nil {
+			log.Fatalln("[Import]", err)
+		}
+
+		var output io.Writer = os.Stdout
+		if validateOutput != "" {
+			file, err := os.Create(validateOutput)
+			if err != nil {
+				log.Fatalln("[Export]", err)
+			}
+			defer file.Close()
+			output = file
+		}
+
+		switch validateFormat {
+		case "json":
+			err = report.WriteJSON(output)
+		case "html":
+			err = report.WriteHTML(output, validateInput)
+		default:
+			err = report.WriteText(output)
+		}
+		if err != nil {
+			log.Fatalln("[Export]", err)
+		}
+
+		if !failOnNone && report.Fails(failOn) {
+			if file, ok := output.(*os.File); ok {
+				file.Close()
+			}
+			os.Exit(1)
+		}
+	},
+}
@@ -1,3 +1 @@
 curl https://data.moopmobility.nl/gtfs/new/gtfs-nl.zip --output gtfs-nl.zip
-unzip -o gtfs-nl.zip -d gtfs-data
-rm gtfs-nl.zip
@@ -1,10 +1,8 @@
 package util
 
 import (
-	"fmt"
 	"os"
 	"strconv"
-	"strings"
 )
 
 func IndexOf(element string, data []string) (int) {
@@ -32,7 +30,7 @@ func ParseInt(str string) int {
 }
 
 func ParseFloat(str string) float64 {
-	i, _ := strconv.ParseFloat(str, 32)
+	i, _ := strconv.ParseFloat(str, 64)
 
 	return i
 }
@@ -44,32 +42,15 @@ func ParseString(i int) string {
 }
 
 func ParseFloatString(i float64) string {
-	s := fmt.Sprintf("%g", i)
+	s := strconv.FormatFloat(i, 'f', -1, 64)
 
 	return s
 }
 
-func CSVRow(data []interface{}) string { // custom CSV row generator as the go CSV library sucks
-
-	row := make([]string, 0)
-
-	for _, value := range data{
-		switch v := value.(type) {
-		case float64:
-			row = append(row, ParseFloatString(v))
-		case int:
-			row = append(row, ParseString(v))
-		default:
-		case string:
-			if strings.Contains(v, ",") {
-				row = append(row, "\"" + v + "\"")
-			} else {
-				row = append(row, v)
-			}
-		}
-	}
-
-	return strings.Join(row, ",") + "\n"
+func FileExists(path string) (bool) {
+	info, err := os.Stat(path)
+	if err != nil { return false }
+	return !info.IsDir()
 }
 
 func DirectoryExists(path string) (bool) {
@@ -77,4 +58,15 @@ func DirectoryExists(path string) (bool) {
 	if err == nil { return true }
 	if os.IsNotExist(err) { return false }
 	return false
-}
\ No newline at end of file
+}
+
+type StringSet map[string]struct{}
+
+func (set StringSet) Add(value string) {
+	set[value] = struct{}{}
+}
+
+func (set StringSet) Has(value string) (bool) {
+	_, ok := set[value]
+	return ok
+}
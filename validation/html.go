@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"html/template"
+	"io"
+)
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>GTFS validation report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 2em; }
+.error { color: #b00020; }
+.warning { color: #a66300; }
+.info { color: #005a9c; }
+summary { cursor: pointer; padding: .3em 0; }
+table { border-collapse: collapse; margin: .5em 0 1em; }
+th, td { text-align: left; padding: .2em .8em; border-bottom: 1px solid #ddd; font-size: 90%; }
+code { background: #f3f3f3; padding: 0 .2em; }
+</style>
+</head>
+<body>
+<h1>GTFS validation report</h1>
+<p>{{if .Source}}<code>{{.Source}}</code>: {{end}}<span class="error">{{.Errors}} errors</span>, <span class="warning">{{.Warnings}} warnings</span>, <span class="info">{{.Infos}} infos</span></p>
+{{range .Severities}}{{if .Groups}}
+<h2 class="{{.Name}}">{{.Title}}</h2>
+{{range .Groups}}<details>
+<summary><code>{{.Rule.Code}}</code> ({{.Count}}{{if lt (len .Issues) .Count}}, first {{len .Issues}} shown{{end}}): {{.Rule.Description}}</summary>
+<table>
+<tr><th>File</th><th>Line</th><th>Field</th><th>Value</th><th>Message</th></tr>
+{{range .Issues}}<tr><td>{{.File}}</td><td>{{if .Line}}{{.Line}}{{end}}</td><td>{{.Field}}</td><td>{{.Value}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+</details>
+{{end}}{{end}}{{end}}{{if not .Total}}<p>No issues found.</p>{{end}}
+</body>
+</html>
+`))
+
+// issues listed per rule in the HTML report, to keep the page usable for large feeds
+const htmlIssuesPerRule = 1000
+
+type htmlGroup struct {
+	issueGroup
+	Count int
+}
+
+type htmlSeverity struct {
+	Name   string
+	Title  string
+	Groups []htmlGroup
+}
+
+// WriteHTML writes a self-contained HTML page listing the issues grouped by severity and rule. source names the
+// validated feed in the heading and may be empty.
+func (report *Report) WriteHTML(w io.Writer, source string) error {
+	severities := []htmlSeverity{{Name: "error", Title: "Errors"}, {Name: "warning", Title: "Warnings"}, {Name: "info", Title: "Infos"}}
+	for _, group := range report.groups() {
+		count := len(group.Issues)
+		if count > htmlIssuesPerRule {
+			group.Issues = group.Issues[:htmlIssuesPerRule]
+		}
+		severity := &severities[Error-group.Rule.Severity]
+		severity.Groups = append(severity.Groups, htmlGroup{group, count})
+	}
+
+	return htmlReport.Execute(w, struct {
+		Source                  string
+		Errors, Warnings, Infos int
+		Total                   int
+		Severities              []htmlSeverity
+	}{source, report.Count(Error), report.Count(Warning), report.Count(Info), len(report.Issues), severities})
+}
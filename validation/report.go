@@ -0,0 +1,125 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// issues shown per rule in the text report, the rest is summarised
+const textIssuesPerRule = 20
+
+// WriteText writes a human readable report, most severe issues first
+func (report *Report) WriteText(w io.Writer) error {
+	b := &strings.Builder{}
+	for _, group := range report.groups() {
+		fmt.Fprintf(b, "%s %s (%d): %s\n", strings.ToUpper(group.Rule.Severity.String()), group.Rule.Code, len(group.Issues), group.Rule.Description)
+		for i, issue := range group.Issues {
+			if i == textIssuesPerRule {
+				fmt.Fprintf(b, "  ... and %d more\n", len(group.Issues)-textIssuesPerRule)
+				break
+			}
+			fmt.Fprintf(b, "  %s: %s\n", issue.location(), issue.Message)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(b, "%d errors, %d warnings, %d infos\n", report.Count(Error), report.Count(Warning), report.Count(Info))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// location formats where the issue was found as file:line field
+func (issue Issue) location() string {
+	location := issue.File
+	if issue.Line > 0 {
+		location += fmt.Sprintf(":%d", issue.Line)
+	}
+	if issue.Field != "" {
+		location += " " + issue.Field
+	}
+	return location
+}
+
+// samples of offending values listed per rule in the JSON report
+const jsonSamplesPerRule = 5
+
+type jsonLocation struct {
+	File  string `json:"file,omitempty"`
+	Line  int    `json:"line,omitempty"`
+	Field string `json:"field,omitempty"`
+}
+
+type jsonIssue struct {
+	Rule     string       `json:"rule"`
+	Severity Severity     `json:"severity"`
+	Location jsonLocation `json:"location"`
+	Value    string       `json:"value,omitempty"`
+	Message  string       `json:"message"`
+}
+
+type jsonRule struct {
+	Code        string   `json:"code"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+	Count       int      `json:"count"`
+	Samples     []string `json:"samples"`
+}
+
+// WriteJSON writes the report as a JSON document with a summary, the rules that were violated with sample values, and
+// every issue with its location
+func (report *Report) WriteJSON(w io.Writer) error {
+	document := struct {
+		Summary map[string]int `json:"summary"`
+		Rules   []jsonRule     `json:"rules"`
+		Issues  []jsonIssue    `json:"issues"`
+	}{
+		Summary: map[string]int{"error": report.Count(Error), "warning": report.Count(Warning), "info": report.Count(Info)},
+		Rules:   make([]jsonRule, 0),
+		Issues:  make([]jsonIssue, 0, len(report.Issues)),
+	}
+
+	for _, group := range report.groups() {
+		rule := jsonRule{Code: group.Rule.Code, Severity: group.Rule.Severity, Description: group.Rule.Description, Count: len(group.Issues), Samples: make([]string, 0)}
+		samples := map[string]bool{}
+		for _, issue := range group.Issues {
+			if issue.Value != "" && !samples[issue.Value] && len(rule.Samples) < jsonSamplesPerRule {
+				samples[issue.Value] = true
+				rule.Samples = append(rule.Samples, issue.Value)
+			}
+		}
+		document.Rules = append(document.Rules, rule)
+	}
+	for _, issue := range report.Issues {
+		document.Issues = append(document.Issues, jsonIssue{
+			Rule:     issue.Rule,
+			Severity: issue.Severity,
+			Location: jsonLocation{File: issue.File, Line: issue.Line, Field: issue.Field},
+			Value:    issue.Value,
+			Message:  issue.Message,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+type issueGroup struct {
+	Rule   Rule
+	Issues []Issue
+}
+
+// groups splits the sorted issues into runs of the same rule
+func (report *Report) groups() []issueGroup {
+	groups := make([]issueGroup, 0)
+	for _, issue := range report.Issues {
+		if len(groups) == 0 || groups[len(groups)-1].Rule.Code != issue.Rule {
+			groups = append(groups, issueGroup{Rule: rulesByCode[issue.Rule]})
+		}
+		last := &groups[len(groups)-1]
+		last.Issues = append(last.Issues, issue)
+	}
+	return groups
+}
@@ -0,0 +1,534 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezones are checked against the embedded tz database, so hosts without zoneinfo give the same result
+)
+
+type Rule struct {
+	Code        string
+	Severity    Severity
+	Description string
+}
+
+// Rules lists every check, in the order they are documented
+var Rules = []Rule{
+	{"missing_file", Error, "A required file is missing from the feed"},
+	{"missing_column", Error, "A required column is missing from a file"},
+	{"unreadable_file", Error, "A file could not be read"},
+	{"invalid_value", Error, "A value could not be parsed as the type its column requires"},
+	{"missing_field", Error, "A required field is empty"},
+	{"duplicate_key", Error, "Several rows share the same ID or key"},
+	{"unknown_reference", Error, "A field refers to an ID that does not exist"},
+	{"out_of_range", Error, "A value is outside the range the specification allows"},
+	{"invalid_date", Error, "A date is not a valid YYYYMMDD date"},
+	{"invalid_timezone", Error, "A timezone is not a known tz database name"},
+	{"invalid_url", Error, "A URL is not an absolute http or https URL"},
+	{"invalid_color", Error, "A color is not a six digit hexadecimal value"},
+	{"invalid_parent_station", Error, "A parent station is missing, not allowed or of the wrong location type"},
+	{"decreasing_time", Error, "A stop time is earlier than the one before it in the trip"},
+	{"decreasing_distance", Error, "A shape_dist_traveled is smaller than the one before it"},
+	{"missing_coordinates", Warning, "A stop or shape point lies at 0,0"},
+	{"stop_time_at_station", Error, "A stop time refers to a station, entrance or node instead of a stop or platform"},
+	{"too_few_stop_times", Warning, "A trip has fewer than two stop times"},
+	{"departure_before_arrival", Error, "A departure time is earlier than the arrival time at the same stop"},
+	{"unused_stop", Info, "A stop is not used by any stop time, stop or transfer"},
+	{"unused_route", Info, "A route has no trips"},
+	{"unused_shape", Info, "A shape is not used by any trip"},
+	{"unused_service", Info, "A service is not used by any trip"},
+}
+
+var rulesByCode = func() map[string]Rule {
+	rules := make(map[string]Rule, len(Rules))
+	for _, rule := range Rules {
+		rules[rule.Code] = rule
+	}
+	return rules
+}()
+
+var colorPattern = regexp.MustCompile(`^[0-9A-Fa-f]{6}$`)
+
+type validator struct {
+	store  *GTFS.Store
+	report Report
+
+	timezones map[string]bool
+	agencies  map[string]bool
+	routes    map[string]bool
+	trips     map[string]bool
+	stops     map[string]GTFS.Stop
+	shapes    map[string]bool
+	services  map[string]bool
+
+	usedStops    map[string]bool
+	usedRoutes   map[string]bool
+	usedShapes   map[string]bool
+	usedServices map[string]bool
+}
+
+func newValidator(store *GTFS.Store) *validator {
+	return &validator{
+		store:        store,
+		timezones:    map[string]bool{},
+		agencies:     map[string]bool{},
+		routes:       map[string]bool{},
+		trips:        map[string]bool{},
+		stops:        map[string]GTFS.Stop{},
+		shapes:       map[string]bool{},
+		services:     map[string]bool{},
+		usedStops:    map[string]bool{},
+		usedRoutes:   map[string]bool{},
+		usedShapes:   map[string]bool{},
+		usedServices: map[string]bool{},
+	}
+}
+
+func (v *validator) add(rule, file string, line int, field, value, message string) {
+	v.report.Issues = append(v.report.Issues, Issue{
+		Rule:     rule,
+		Severity: rulesByCode[rule].Severity,
+		File:     file,
+		Line:     line,
+		Field:    field,
+		Value:    value,
+		Message:  message,
+	})
+}
+
+// line returns the line of the row at index i of a table, assuming the table was read from a single file
+func line(i int) int {
+	return i + 2
+}
+
+func (v *validator) check() {
+	// tables that others refer to are checked first, so that the references can be resolved
+	v.checkAgencies()
+	v.checkStops()
+	v.checkRoutes()
+	v.checkShapes()
+	v.checkCalendars()
+	v.checkCalendarDates()
+	v.checkTrips()
+	v.checkStopTimes()
+	v.checkTransfers()
+	v.checkUnused()
+}
+
+func (v *validator) required(file string, i int, field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add("missing_field", file, line(i), field, "", field+" is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) reference(file string, i int, field, value string, known func(string) bool, target string) {
+	if value != "" && !known(value) {
+		v.add("unknown_reference", file, line(i), field, value, fmt.Sprintf("%s %q does not exist in %s", field, value, target))
+	}
+}
+
+func (v *validator) enum(file string, i int, field string, value int, allowed ...int) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add("out_of_range", file, line(i), field, strconv.Itoa(value), fmt.Sprintf("%s should be one of %s", field, strings.Trim(fmt.Sprint(allowed), "[]")))
+}
+
+func (v *validator) duplicate(seen map[string]bool, file string, i int, field, key string) {
+	if seen[key] {
+		v.add("duplicate_key", file, line(i), field, key, fmt.Sprintf("%s %s is used by more than one row", field, key))
+	}
+	seen[key] = true
+}
+
+func (v *validator) timezone(file string, i int, field, value string) {
+	if value == "" {
+		return
+	}
+	valid, known := v.timezones[value]
+	if !known {
+		_, err := time.LoadLocation(value)
+		valid = err == nil && value != "Local"
+		v.timezones[value] = valid
+	}
+	if !valid {
+		v.add("invalid_timezone", file, line(i), field, value, value+" is not a tz database timezone")
+	}
+}
+
+func (v *validator) url(file string, i int, field, value string) {
+	if value == "" {
+		return
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		v.add("invalid_url", file, line(i), field, value, value+" is not an http or https URL")
+	}
+}
+
+func (v *validator) color(file string, i int, field, value string) {
+	if value != "" && !colorPattern.MatchString(value) {
+		v.add("invalid_color", file, line(i), field, value, value+" is not a hexadecimal color like FFFFFF")
+	}
+}
+
+func (v *validator) date(file string, i int, field, value string) bool {
+	if !v.required(file, i, field, value) {
+		return false
+	}
+	if _, err := GTFS.ParseDate(value); err != nil {
+		v.add("invalid_date", file, line(i), field, value, value+" is not a YYYYMMDD date")
+		return false
+	}
+	return true
+}
+
+func (v *validator) coordinates(file string, i int, latField string, lat float64, lonField string, lon float64) {
+	if lat < -90 || lat > 90 {
+		v.add("out_of_range", file, line(i), latField, strconv.FormatFloat(lat, 'f', -1, 64), latField+" should be between -90 and 90")
+	}
+	if lon < -180 || lon > 180 {
+		v.add("out_of_range", file, line(i), lonField, strconv.FormatFloat(lon, 'f', -1, 64), lonField+" should be between -180 and 180")
+	}
+	if lat == 0 && lon == 0 {
+		v.add("missing_coordinates", file, line(i), latField, "0", "coordinates are at 0,0")
+	}
+}
+
+func (v *validator) checkAgencies() {
+	const file = "agency.txt"
+	for i, agency := range v.store.Agency {
+		if len(v.store.Agency) > 1 {
+			v.required(file, i, "agency_id", agency.Id)
+		}
+		if agency.Id != "" {
+			v.duplicate(v.agencies, file, i, "agency_id", agency.Id)
+		}
+		v.required(file, i, "agency_name", agency.Name)
+		if v.required(file, i, "agency_url", agency.URL) {
+			v.url(file, i, "agency_url", agency.URL)
+		}
+		if v.required(file, i, "agency_timezone", agency.Timezone) {
+			v.timezone(file, i, "agency_timezone", agency.Timezone)
+		}
+	}
+}
+
+func (v *validator) checkStops() {
+	const file = "stops.txt"
+	seen := map[string]bool{}
+	for i, stop := range v.store.Stop {
+		if v.required(file, i, "stop_id", stop.Id) {
+			v.duplicate(seen, file, i, "stop_id", stop.Id)
+			v.stops[stop.Id] = stop
+		}
+		v.enum(file, i, "location_type", stop.LocationType, 0, 1, 2, 3, 4)
+		v.enum(file, i, "wheelchair_boarding", stop.WheelchairBoarding, 0, 1, 2)
+		v.timezone(file, i, "stop_timezone", stop.StopTimezone)
+		if stop.LocationType <= 2 {
+			v.required(file, i, "stop_name", stop.Name)
+			if stop.HasCoordinates() {
+				v.coordinates(file, i, "stop_lat", stop.Lat, "stop_lon", stop.Lon)
+			} else {
+				v.add("missing_field", file, line(i), "stop_lat", "", "stop_lat and stop_lon are required for stops, stations and entrances")
+			}
+		}
+	}
+
+	// parent stations can only be checked once every stop is known
+	for i, stop := range v.store.Stop {
+		switch {
+		case stop.LocationType == 1 && stop.ParentStation != "":
+			v.add("invalid_parent_station", file, line(i), "parent_station", stop.ParentStation, "a station can't have a parent station")
+		case stop.LocationType >= 2 && stop.LocationType <= 4 && stop.ParentStation == "":
+			v.add("invalid_parent_station", file, line(i), "parent_station", "", "entrances, nodes and boarding areas need a parent station")
+		case stop.ParentStation != "":
+			parent, ok := v.stops[stop.ParentStation]
+			if !ok {
+				v.reference(file, i, "parent_station", stop.ParentStation, func(string) bool { return false }, file)
+				continue
+			}
+			v.usedStops[parent.Id] = true
+			expected := 1
+			if stop.LocationType == 4 {
+				expected = 0 // boarding areas belong to a platform
+			}
+			if parent.LocationType != expected {
+				v.add("invalid_parent_station", file, line(i), "parent_station", stop.ParentStation,
+					fmt.Sprintf("parent station %s has location_type %d, expected %d", parent.Id, parent.LocationType, expected))
+			}
+		}
+	}
+}
+
+func (v *validator) checkRoutes() {
+	const file = "routes.txt"
+	for i, route := range v.store.Route {
+		if v.required(file, i, "route_id", route.RouteId) {
+			v.duplicate(v.routes, file, i, "route_id", route.RouteId)
+		}
+		if len(v.store.Agency) > 1 {
+			v.required(file, i, "agency_id", route.AgencyId)
+		}
+		v.reference(file, i, "agency_id", route.AgencyId, func(id string) bool { return v.agencies[id] }, "agency.txt")
+		if strings.TrimSpace(route.RouteShortName) == "" && strings.TrimSpace(route.RouteLongName) == "" {
+			v.add("missing_field", file, line(i), "route_short_name", "", "route_short_name or route_long_name is required")
+		}
+		if v.required(file, i, "route_type", route.RouteType) {
+			routeType, err := strconv.Atoi(strings.TrimSpace(route.RouteType))
+			if err != nil || !validRouteType(routeType) {
+				v.add("out_of_range", file, line(i), "route_type", route.RouteType, "route_type should be 0 to 7, 11, 12 or an extended type from 100 to 1799")
+			}
+		}
+		v.color(file, i, "route_color", route.RouteColor)
+		v.color(file, i, "route_text_color", route.RouteTextColor)
+		v.url(file, i, "route_url", route.RouteURL)
+	}
+}
+
+// validRouteType accepts the basic route types and the extended (Google Transit) ones
+func validRouteType(routeType int) bool {
+	return (routeType >= 0 && routeType <= 7) || routeType == 11 || routeType == 12 || (routeType >= 100 && routeType <= 1799)
+}
+
+func (v *validator) checkShapes() {
+	const file = "shapes.txt"
+	seen := map[string]bool{}
+	points := map[string][]int{}
+	for i, point := range v.store.Shape {
+		if !v.required(file, i, "shape_id", point.Id) {
+			continue
+		}
+		v.duplicate(seen, file, i, "shape_pt_sequence", point.Id+"/"+strconv.Itoa(point.PTSequence))
+		v.shapes[point.Id] = true
+		if point.HasCoordinates() {
+			v.coordinates(file, i, "shape_pt_lat", point.Lat, "shape_pt_lon", point.Lon)
+		} else {
+			v.add("missing_field", file, line(i), "shape_pt_lat", "", "shape_pt_lat and shape_pt_lon are required")
+		}
+		points[point.Id] = append(points[point.Id], i)
+	}
+
+	for _, indices := range points {
+		sort.SliceStable(indices, func(a, b int) bool {
+			return v.store.Shape[indices[a]].PTSequence < v.store.Shape[indices[b]].PTSequence
+		})
+		last := 0.0
+		for _, i := range indices {
+			if !v.store.Shape[i].HasDistTraveled() {
+				continue // feeds may give distances for some points only
+			}
+			dist := v.store.Shape[i].DistTraveled
+			if dist < last {
+				v.add("decreasing_distance", file, line(i), "shape_dist_traveled", strconv.FormatFloat(dist, 'f', -1, 64),
+					fmt.Sprintf("shape_dist_traveled decreases from %s", strconv.FormatFloat(last, 'f', -1, 64)))
+			}
+			if dist > last {
+				last = dist
+			}
+		}
+	}
+}
+
+func (v *validator) checkCalendars() {
+	const file = "calendar.txt"
+	for i, calendar := range v.store.Calendar {
+		if v.required(file, i, "service_id", calendar.ServiceId) {
+			v.duplicate(v.services, file, i, "service_id", calendar.ServiceId)
+		}
+		days := []int{calendar.Monday, calendar.Tuesday, calendar.Wednesday, calendar.Thursday, calendar.Friday, calendar.Saturday, calendar.Sunday}
+		for day, value := range days {
+			v.enum(file, i, strings.ToLower(time.Weekday((day+1)%7).String()), value, 0, 1)
+		}
+		if v.date(file, i, "start_date", calendar.StartDate) && v.date(file, i, "end_date", calendar.EndDate) && calendar.EndDate < calendar.StartDate {
+			v.add("out_of_range", file, line(i), "end_date", calendar.EndDate, "end_date is before start_date "+calendar.StartDate)
+		}
+	}
+}
+
+func (v *validator) checkCalendarDates() {
+	const file = "calendar_dates.txt"
+	seen := map[string]bool{}
+	for i, calendarDate := range v.store.CalendarDates {
+		if v.required(file, i, "service_id", calendarDate.ServiceId) {
+			v.services[calendarDate.ServiceId] = true
+		}
+		if v.date(file, i, "date", calendarDate.Date) {
+			v.duplicate(seen, file, i, "date", calendarDate.ServiceId+"/"+calendarDate.Date)
+		}
+		v.enum(file, i, "exception_type", calendarDate.ExceptionType, GTFS.ServiceAdded, GTFS.ServiceRemoved)
+	}
+}
+
+func (v *validator) checkTrips() {
+	const file = "trips.txt"
+	for i, trip := range v.store.Trip {
+		if v.required(file, i, "trip_id", trip.TripId) {
+			v.duplicate(v.trips, file, i, "trip_id", trip.TripId)
+		}
+		if v.required(file, i, "route_id", trip.RouteId) {
+			v.reference(file, i, "route_id", trip.RouteId, func(id string) bool { return v.routes[id] }, "routes.txt")
+			v.usedRoutes[trip.RouteId] = true
+		}
+		if v.required(file, i, "service_id", trip.ServiceId) {
+			v.reference(file, i, "service_id", trip.ServiceId, func(id string) bool { return v.services[id] }, "calendar.txt or calendar_dates.txt")
+			v.usedServices[trip.ServiceId] = true
+		}
+		if trip.ShapeId != "" {
+			v.reference(file, i, "shape_id", trip.ShapeId, func(id string) bool { return v.shapes[id] }, "shapes.txt")
+			v.usedShapes[trip.ShapeId] = true
+		}
+		v.enum(file, i, "direction_id", trip.DirectionId, 0, 1)
+		v.enum(file, i, "wheelchair_accessible", trip.WheelchairAccessible, 0, 1, 2)
+		v.enum(file, i, "bikes_allowed", trip.BikesAllowed, 0, 1, 2)
+	}
+}
+
+func (v *validator) checkStopTimes() {
+	const file = "stop_times.txt"
+	seen := map[string]bool{}
+	byTrip := map[string][]int{}
+	for i, stopTime := range v.store.StopTime {
+		if v.required(file, i, "trip_id", stopTime.TripId) {
+			v.reference(file, i, "trip_id", stopTime.TripId, func(id string) bool { return v.trips[id] }, "trips.txt")
+			v.duplicate(seen, file, i, "stop_sequence", stopTime.TripId+"/"+strconv.Itoa(stopTime.Sequence))
+			byTrip[stopTime.TripId] = append(byTrip[stopTime.TripId], i)
+		}
+		if v.required(file, i, "stop_id", stopTime.StopId) {
+			stop, ok := v.stops[stopTime.StopId]
+			if !ok {
+				v.reference(file, i, "stop_id", stopTime.StopId, func(string) bool { return false }, "stops.txt")
+			} else if stop.LocationType != 0 {
+				v.add("stop_time_at_station", file, line(i), "stop_id", stopTime.StopId, fmt.Sprintf("stop %s has location_type %d", stop.Id, stop.LocationType))
+			}
+			v.usedStops[stopTime.StopId] = true
+		}
+		v.enum(file, i, "pickup_type", stopTime.PickUpType, 0, 1, 2, 3)
+		v.enum(file, i, "drop_off_type", stopTime.DropOffType, 0, 1, 2, 3)
+		v.enum(file, i, "timepoint", stopTime.Timepoint, 0, 1)
+		if stopTime.ArrivalTime.IsSet() && stopTime.DepartureTime.IsSet() && stopTime.DepartureTime < stopTime.ArrivalTime {
+			v.add("departure_before_arrival", file, line(i), "departure_time", stopTime.DepartureTime.String(), "departure_time is before arrival_time "+stopTime.ArrivalTime.String())
+		}
+	}
+
+	for _, trip := range v.store.Trip {
+		if len(byTrip[trip.TripId]) < 2 {
+			v.add("too_few_stop_times", "trips.txt", 0, "trip_id", trip.TripId, fmt.Sprintf("trip %s has %d stop times", trip.TripId, len(byTrip[trip.TripId])))
+		}
+	}
+
+	for _, indices := range byTrip {
+		v.checkTripTimes(file, indices)
+	}
+}
+
+// checkTripTimes checks the stop times of a single trip, given as indices into the stop_times table
+func (v *validator) checkTripTimes(file string, indices []int) {
+	stopTimes := v.store.StopTime
+	sort.SliceStable(indices, func(a, b int) bool {
+		return stopTimes[indices[a]].Sequence < stopTimes[indices[b]].Sequence
+	})
+
+	// the first and last stop of a trip need times, the ones in between may be interpolated
+	for _, i := range []int{indices[0], indices[len(indices)-1]} {
+		if !stopTimes[i].ArrivalTime.IsSet() {
+			v.add("missing_field", file, line(i), "arrival_time", "", "arrival_time is required at the first and last stop of a trip")
+		}
+		if !stopTimes[i].DepartureTime.IsSet() {
+			v.add("missing_field", file, line(i), "departure_time", "", "departure_time is required at the first and last stop of a trip")
+		}
+	}
+
+	last := GTFS.NoTime
+	lastDist := 0.0
+	for _, i := range indices {
+		stopTime := stopTimes[i]
+		for _, t := range []struct {
+			field string
+			value GTFS.Time
+		}{{"arrival_time", stopTime.ArrivalTime}, {"departure_time", stopTime.DepartureTime}} {
+			if !t.value.IsSet() {
+				continue
+			}
+			if last.IsSet() && t.value < last {
+				v.add("decreasing_time", file, line(i), t.field, t.value.String(), fmt.Sprintf("%s is before %s at the previous stop", t.field, last.String()))
+			}
+			last = t.value
+		}
+
+		if !stopTime.HasShapeDistTraveled() {
+			continue // feeds may give distances for some stops only
+		}
+		if stopTime.ShapeDistTraveled < lastDist {
+			v.add("decreasing_distance", file, line(i), "shape_dist_traveled", strconv.FormatFloat(stopTime.ShapeDistTraveled, 'f', -1, 64),
+				fmt.Sprintf("shape_dist_traveled decreases from %s", strconv.FormatFloat(lastDist, 'f', -1, 64)))
+		}
+		if stopTime.ShapeDistTraveled > lastDist {
+			lastDist = stopTime.ShapeDistTraveled
+		}
+	}
+}
+
+func (v *validator) checkTransfers() {
+	const file = "transfers.txt"
+	knownStop := func(id string) bool {
+		_, ok := v.stops[id]
+		return ok
+	}
+	for i, transfer := range v.store.Transfer {
+		v.reference(file, i, "from_stop_id", transfer.FromStopId, knownStop, "stops.txt")
+		v.reference(file, i, "to_stop_id", transfer.ToStopId, knownStop, "stops.txt")
+		v.reference(file, i, "from_route_id", transfer.FromRouteId, func(id string) bool { return v.routes[id] }, "routes.txt")
+		v.reference(file, i, "to_route_id", transfer.ToRouteId, func(id string) bool { return v.routes[id] }, "routes.txt")
+		v.reference(file, i, "from_trip_id", transfer.FromTripId, func(id string) bool { return v.trips[id] }, "trips.txt")
+		v.reference(file, i, "to_trip_id", transfer.ToTripId, func(id string) bool { return v.trips[id] }, "trips.txt")
+		v.enum(file, i, "transfer_type", transfer.TransferType, 0, 1, 2, 3, 4, 5)
+		v.usedStops[transfer.FromStopId] = true
+		v.usedStops[transfer.ToStopId] = true
+	}
+}
+
+func (v *validator) checkUnused() {
+	for i, stop := range v.store.Stop {
+		if !v.usedStops[stop.Id] && stop.LocationType == 0 {
+			v.add("unused_stop", "stops.txt", line(i), "stop_id", stop.Id, "stop "+stop.Id+" is not used")
+		}
+	}
+	for i, route := range v.store.Route {
+		if !v.usedRoutes[route.RouteId] {
+			v.add("unused_route", "routes.txt", line(i), "route_id", route.RouteId, "route "+route.RouteId+" has no trips")
+		}
+	}
+
+	reported := map[string]bool{}
+	for i, point := range v.store.Shape {
+		if !v.usedShapes[point.Id] && !reported[point.Id] {
+			reported[point.Id] = true
+			v.add("unused_shape", "shapes.txt", line(i), "shape_id", point.Id, "shape "+point.Id+" is not used by any trip")
+		}
+	}
+
+	reported = map[string]bool{}
+	for i, calendar := range v.store.Calendar {
+		if !v.usedServices[calendar.ServiceId] && !reported[calendar.ServiceId] {
+			reported[calendar.ServiceId] = true
+			v.add("unused_service", "calendar.txt", line(i), "service_id", calendar.ServiceId, "service "+calendar.ServiceId+" is not used by any trip")
+		}
+	}
+	for i, calendarDate := range v.store.CalendarDates {
+		if !v.usedServices[calendarDate.ServiceId] && !reported[calendarDate.ServiceId] {
+			reported[calendarDate.ServiceId] = true
+			v.add("unused_service", "calendar_dates.txt", line(i), "service_id", calendarDate.ServiceId, "service "+calendarDate.ServiceId+" is not used by any trip")
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (severity Severity) String() string {
+	return severityNames[severity]
+}
+
+func ParseSeverity(value string) (Severity, error) {
+	for i, name := range severityNames {
+		if strings.EqualFold(value, name) {
+			return Severity(i), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown severity %q, expected one of %s", value, strings.Join(severityNames, ", "))
+}
+
+func (severity Severity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+// Issue is a single problem found in a feed. Line counts records from the header being line 1, and is 0 for issues
+// concerning a whole file.
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Field    string   `json:"field,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Message  string   `json:"message"`
+}
+
+type Report struct {
+	Issues []Issue `json:"issues"`
+}
+
+// Count returns the number of issues of the given severity
+func (report *Report) Count(severity Severity) int {
+	count := 0
+	for _, issue := range report.Issues {
+		if issue.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Fails tells whether the report has issues of the given severity or worse
+func (report *Report) Fails(severity Severity) bool {
+	for _, issue := range report.Issues {
+		if issue.Severity >= severity {
+			return true
+		}
+	}
+	return false
+}
+
+// sort orders the issues from most to least severe, and by location within a severity
+func (report *Report) sort() {
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
+
+// files every feed has to contain; a feed also needs at least one of calendar.txt and calendar_dates.txt
+var requiredFiles = []string{"agency", "stops", "routes", "trips", "stop_times"}
+
+// Validate reads the feed stored at path, which is either a directory or a zip archive, and checks it. Problems
+// reading the feed are reported as issues; the returned error is only set when the feed can't be opened at all.
+func Validate(path string) (*Report, error) {
+	reader, err := GTFS.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	v := newValidator(&GTFS.Store{Lenient: true})
+
+	for _, fileType := range requiredFiles {
+		if !reader.HasFile(fileType) {
+			v.add("missing_file", fileType+".txt", 0, "", "", "required file is missing")
+		}
+	}
+	if !reader.HasFile("calendar") && !reader.HasFile("calendar_dates") {
+		v.add("missing_file", "calendar.txt", 0, "", "", "neither calendar.txt nor calendar_dates.txt is present")
+	}
+
+	for _, fileType := range GTFS.FileTypes {
+		if !reader.HasFile(fileType) {
+			continue
+		}
+		if err := v.store.LoadFrom(reader, fileType); err != nil {
+			v.addReadError(fileType, err)
+		}
+	}
+	for _, warning := range v.store.Warnings {
+		v.add("invalid_value", filepath.Base(warning.File), warning.Line, warning.Column, warning.Value, warning.Err.Error())
+	}
+
+	v.check()
+	v.report.sort()
+	return &v.report, nil
+}
+
+// ValidateStore checks the contents of a store that was already loaded
+func ValidateStore(store *GTFS.Store) *Report {
+	v := newValidator(store)
+	v.check()
+	v.report.sort()
+	return &v.report
+}
+
+// addReadError reports an error that stopped a file from being read
+func (v *validator) addReadError(fileType string, err error) {
+	fileName := fileType + ".txt"
+	var parseError *GTFS.ParseError
+	if !errors.As(err, &parseError) {
+		v.add("unreadable_file", fileName, 0, "", "", err.Error())
+		return
+	}
+
+	if errors.Is(err, GTFS.ErrMissingColumn) {
+		for _, column := range strings.Split(parseError.Column, ", ") {
+			v.add("missing_column", fileName, 1, column, "", "required column is missing, the file was not read")
+		}
+		return
+	}
+	v.add("unreadable_file", fileName, parseError.Line, parseError.Column, parseError.Value, parseError.Err.Error())
+}
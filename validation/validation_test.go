@@ -0,0 +1,194 @@
+package validation
+
+import (
+	"github.com/Gerrist/gtfs-cli/GTFS"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func validStore() *GTFS.Store {
+	return &GTFS.Store{
+		Agency:        []GTFS.Agency{{Id: "A", Name: "Agency", URL: "https://example.com", Timezone: "Europe/Amsterdam"}},
+		CalendarDates: []GTFS.CalendarDate{{ServiceId: "s", Date: "20261019", ExceptionType: GTFS.ServiceAdded}},
+		Route:         []GTFS.Route{{RouteId: "r", AgencyId: "A", RouteShortName: "1", RouteType: "3"}},
+		Stop: []GTFS.Stop{
+			{Id: "a", Name: "A", Lat: 52, Lon: 5},
+			{Id: "b", Name: "B", Lat: 52.1, Lon: 5},
+		},
+		StopTime: []GTFS.StopTime{
+			{TripId: "t", Sequence: 1, StopId: "a", ArrivalTime: GTFS.NewTime(8, 0, 0), DepartureTime: GTFS.NewTime(8, 0, 0)},
+			{TripId: "t", Sequence: 2, StopId: "b", ArrivalTime: GTFS.NewTime(8, 10, 0), DepartureTime: GTFS.NewTime(8, 10, 0)},
+		},
+		Trip: []GTFS.Trip{{RouteId: "r", ServiceId: "s", TripId: "t"}},
+	}
+}
+
+func TestValidateStoreValid(t *testing.T) {
+	report := ValidateStore(validStore())
+	if len(report.Issues) != 0 {
+		t.Errorf("expected no issues, got %+v", report.Issues)
+	}
+}
+
+func TestValidateStore(t *testing.T) {
+	store := validStore()
+	store.Trip = append(store.Trip, GTFS.Trip{RouteId: "missing", ServiceId: "s", TripId: "t"})
+	store.Stop[0].Lat = 100
+	store.Stop[1].LocationType = 7
+	store.StopTime[1].DepartureTime = GTFS.NewTime(8, 5, 0)
+	store.CalendarDates[0].ExceptionType = 3
+
+	report := ValidateStore(store)
+
+	expected := map[string]bool{
+		"duplicate_key":            false,
+		"unknown_reference":        false,
+		"out_of_range":             false,
+		"decreasing_time":          false,
+		"departure_before_arrival": false,
+	}
+	for _, issue := range report.Issues {
+		if _, ok := expected[issue.Rule]; ok {
+			expected[issue.Rule] = true
+		}
+	}
+	for rule, found := range expected {
+		if !found {
+			t.Errorf("expected an issue for rule %s, got %+v", rule, report.Issues)
+		}
+	}
+
+	for i := 1; i < len(report.Issues); i++ {
+		if report.Issues[i].Severity > report.Issues[i-1].Severity {
+			t.Fatal("issues are not sorted by severity")
+		}
+	}
+}
+
+func TestReportFormats(t *testing.T) {
+	store := validStore()
+	store.Stop[1].Lat = 100
+	report := ValidateStore(store)
+
+	if !report.Fails(Error) || !report.Fails(Warning) {
+		t.Error("expected the report to fail on errors and warnings")
+	}
+	if ValidateStore(validStore()).Fails(Info) {
+		t.Error("expected a valid feed not to fail")
+	}
+
+	json := &strings.Builder{}
+	if err := report.WriteJSON(json); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(json.String(), `"code": "out_of_range"`) || !strings.Contains(json.String(), `"samples": [
+        "100"
+      ]`) {
+		t.Errorf("JSON report is missing the rule or its samples:\n%s", json)
+	}
+
+	html := &strings.Builder{}
+	if err := report.WriteHTML(html, "feed.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<code>out_of_range</code>") {
+		t.Errorf("HTML report is missing the rule:\n%s", html)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"agency.txt":     "agency_id,agency_name,agency_url,agency_timezone\nA,Agency,https://example.com,Europe/Amsterdam\n",
+		"stops.txt":      "stop_id,stop_name,stop_lat,stop_lon\na,A,52,5\nb,B,north,5\n",
+		"trips.txt":      "route_id,trip_id\nr,t\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nt,08:00:00,08:00:00,\"a\"b,1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Validate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{
+		"missing_file/routes.txt":        false,
+		"missing_file/calendar.txt":      false,
+		"missing_column/trips.txt":       false,
+		"unreadable_file/stop_times.txt": false,
+		"invalid_value/stops.txt":        false,
+	}
+	for _, issue := range report.Issues {
+		key := issue.Rule + "/" + issue.File
+		if _, ok := expected[key]; ok {
+			expected[key] = true
+		}
+		if issue.Rule == "invalid_timezone" {
+			t.Errorf("unexpected timezone issue %+v", issue)
+		}
+		if issue.Rule == "missing_column" && (issue.Field != "service_id" || issue.Line != 1) {
+			t.Errorf("expected service_id to be missing on line 1, got %+v", issue)
+		}
+		if issue.Rule == "invalid_value" && (issue.Line != 3 || issue.Field != "stop_lat" || issue.Value != "north") {
+			t.Errorf("expected an invalid stop_lat on line 3, got %+v", issue)
+		}
+	}
+	for key, found := range expected {
+		if !found {
+			t.Errorf("expected an issue %s, got %+v", key, report.Issues)
+		}
+	}
+
+	if _, err := Validate(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a feed that doesn't exist")
+	}
+}
+
+func TestValidateEmptyValues(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"agency.txt":         "agency_id,agency_name,agency_url,agency_timezone\nA,Agency,https://example.com,Europe/Amsterdam\n",
+		"calendar_dates.txt": "service_id,date,exception_type\ns,20261019,1\n",
+		"routes.txt":         "route_id,agency_id,route_short_name,route_type\nr,A,1,3\n",
+		"trips.txt":          "route_id,service_id,trip_id,shape_id\nr,s,t,sh\n",
+		"stops.txt":          "stop_id,stop_name,stop_lat,stop_lon\na,A,52,5\nb,B,,\nc,C,0,0\n",
+		"shapes.txt":         "shape_id,shape_pt_sequence,shape_pt_lat,shape_pt_lon,shape_dist_traveled\nsh,1,52,5,0\nsh,2,52.1,5,1.1\nsh,3,52.2,5,\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence,shape_dist_traveled\n" +
+			"t,08:00:00,08:00:00,a,1,\nt,08:05:00,08:05:00,b,2,1.1\nt,08:10:00,08:10:00,c,3,\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Validate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// distances given for some points only don't decrease, empty coordinates are missing and 0,0 is suspicious
+	found := map[string]bool{}
+	for _, issue := range report.Issues {
+		found[issue.Rule] = true
+		switch issue.Rule {
+		case "decreasing_distance":
+			t.Errorf("unexpected issue %+v", issue)
+		case "missing_field":
+			if issue.File != "stops.txt" || issue.Line != 3 || issue.Severity != Error {
+				t.Errorf("expected the coordinates of stop b to be missing, got %+v", issue)
+			}
+		case "missing_coordinates":
+			if issue.File != "stops.txt" || issue.Line != 4 {
+				t.Errorf("expected stop c at 0,0, got %+v", issue)
+			}
+		}
+	}
+	if !found["missing_field"] || !found["missing_coordinates"] {
+		t.Errorf("expected missing coordinates and coordinates at 0,0, got %+v", report.Issues)
+	}
+}